@@ -0,0 +1,130 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ImageProblem is an item whose image can't be served, found by VerifyImages.
+// Such an item is shown with the default image.
+type ImageProblem struct {
+	Item
+	Problem string `json:"problem"`
+}
+
+// VerifyImages checks that the image of every item exists in imgDirPath.
+// The items are streamed, so that a large catalog can be verified.
+func VerifyImages(ctx context.Context, repo ItemRepository, imgDirPath string) ([]ImageProblem, error) {
+	problems := []ImageProblem{}
+	err := repo.Stream(ctx, "", "", func(item Item) error {
+		_, err := buildImagePath(imgDirPath, item.ImageName)
+		switch {
+		case err == nil:
+		case errors.Is(err, errImageNotFound):
+			problems = append(problems, ImageProblem{Item: item, Problem: "missing"})
+		default:
+			problems = append(problems, ImageProblem{Item: item, Problem: "invalid name"})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return problems, nil
+}
+
+// RestoreDatabase replaces the database of dsn with a backup made by Backup.
+// The backup is checked to be an intact database of this app first, and the database is replaced atomically,
+// so a bad backup leaves the database as it was. The server must be stopped while restoring.
+func RestoreDatabase(ctx context.Context, dsn, backupPath string) error {
+	if err := checkBackup(ctx, backupPath); err != nil {
+		return err
+	}
+
+	path := databasePath(dsn)
+	src, err := os.Open(backupPath)
+	if err != nil {
+		return fmt.Errorf("failed to open backup: %w", err)
+	}
+	defer src.Close()
+
+	// copy next to the database so that it can be renamed over it atomically
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".restore-*.sqlite3")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+	if _, err := io.Copy(tmp, src); err != nil {
+		return fmt.Errorf("failed to copy backup: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		return fmt.Errorf("failed to copy backup: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to copy backup: %w", err)
+	}
+	// keep the permissions of the database, since a temporary file is only readable by its owner
+	mode := os.FileMode(0644)
+	if stat, err := os.Stat(path); err == nil {
+		mode = stat.Mode().Perm()
+	}
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return fmt.Errorf("failed to copy backup: %w", err)
+	}
+
+	// the journal of the old database must not be applied to the restored one
+	for _, suffix := range []string{"-wal", "-shm", "-journal"} {
+		if err := os.Remove(path + suffix); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to remove %s: %w", path+suffix, err)
+		}
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace database: %w", err)
+	}
+	return nil
+}
+
+// checkBackup checks that the file at path is an intact SQLite database with the tables of this app.
+func checkBackup(ctx context.Context, path string) error {
+	if _, err := os.Stat(path); err != nil {
+		return fmt.Errorf("failed to open backup: %w", err)
+	}
+	db, err := sql.Open("sqlite3", "file:"+path+"?mode=ro")
+	if err != nil {
+		return fmt.Errorf("failed to open backup: %w", err)
+	}
+	defer db.Close()
+
+	var result string
+	if err := db.QueryRowContext(ctx, "PRAGMA integrity_check").Scan(&result); err != nil {
+		return fmt.Errorf("%w: %s is not a database: %w", errInvalidInput, path, err)
+	}
+	if result != "ok" {
+		return fmt.Errorf("%w: %s is corrupted: %s", errInvalidInput, path, result)
+	}
+	for _, table := range []string{"items", "categories"} {
+		var n int
+		err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?", table).Scan(&n)
+		if err != nil {
+			return fmt.Errorf("failed to read backup: %w", err)
+		}
+		if n == 0 {
+			return fmt.Errorf("%w: %s has no %s table", errInvalidInput, path, table)
+		}
+	}
+	return nil
+}
+
+// databasePath returns the path of the database file of an SQLite DSN such as "file:db/mercari.sqlite3?_busy_timeout=5000".
+func databasePath(dsn string) string {
+	path := strings.TrimPrefix(dsn, "file:")
+	path, _, _ = strings.Cut(path, "?")
+	return path
+}
@@ -0,0 +1,144 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"go.uber.org/mock/gomock"
+)
+
+func TestVerifyImages(t *testing.T) {
+	t.Parallel()
+
+	type wants struct {
+		problems []ImageProblem
+		err      bool
+	}
+
+	cases := map[string]struct {
+		items     []Item
+		streamErr error
+		wants
+	}{
+		"ok: every image exists": {
+			items: []Item{
+				{ID: 1, Name: "jacket", Category: "fashion", ImageName: "jacket1.jpg"},
+				{ID: 2, Name: "iPhone", Category: "phone", ImageName: "default.jpg"},
+			},
+			wants: wants{problems: []ImageProblem{}},
+		},
+		"ok: missing and invalid images are reported": {
+			items: []Item{
+				{ID: 1, Name: "jacket", Category: "fashion", ImageName: "jacket1.jpg"},
+				{ID: 2, Name: "boots", Category: "fashion", ImageName: "missing.jpg"},
+				{ID: 3, Name: "passwd", Category: "fashion", ImageName: "../../etc/passwd"},
+			},
+			wants: wants{problems: []ImageProblem{
+				{Item: Item{ID: 2, Name: "boots", Category: "fashion", ImageName: "missing.jpg"}, Problem: "missing"},
+				{Item: Item{ID: 3, Name: "passwd", Category: "fashion", ImageName: "../../etc/passwd"}, Problem: "invalid name"},
+			}},
+		},
+		"ng: failed to stream items": {
+			streamErr: errors.New("database is locked"),
+			wants:     wants{err: true},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ctrl := gomock.NewController(t)
+			mockRepo := NewMockItemRepository(ctrl)
+			mockRepo.EXPECT().Stream(gomock.Any(), "", "", gomock.Any()).DoAndReturn(
+				func(_ context.Context, _, _ string, fn func(Item) error) error {
+					for _, item := range tc.items {
+						if err := fn(item); err != nil {
+							return err
+						}
+					}
+					return tc.streamErr
+				})
+
+			got, err := VerifyImages(context.Background(), mockRepo, "../images")
+			if tc.wants.err {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(tc.wants.problems, got); diff != "" {
+				t.Errorf("unexpected problems (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestBackupAndRestore(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping e2e test")
+	}
+
+	ctx := context.Background()
+	dir := t.TempDir()
+	dsn := "file:" + filepath.Join(dir, "mercari.sqlite3") + "?_busy_timeout=5000"
+	// the admin command opens the database created by the server
+	created, err := NewItemRepository(dsn, "../db/items.sql")
+	if err != nil {
+		t.Fatalf("failed to create database: %v", err)
+	}
+	created.Close()
+	repo, err := OpenAdminRepository(dsn)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	item := &Item{Name: "jacket", Category: "fashion", ImageName: "default.jpg"}
+	if err := repo.Insert(ctx, item); err != nil {
+		t.Fatalf("failed to insert item: %v", err)
+	}
+	backup := filepath.Join(dir, "backup.sqlite3")
+	if err := repo.Backup(ctx, backup); err != nil {
+		t.Fatalf("failed to back up database: %v", err)
+	}
+	if err := repo.Backup(ctx, backup); !errors.Is(err, errInvalidInput) {
+		t.Errorf("expected an existing backup not to be overwritten, got %v", err)
+	}
+
+	// the item deleted after the backup is brought back by restoring it
+	if err := repo.Delete(ctx, item.ID); err != nil {
+		t.Fatalf("failed to delete item: %v", err)
+	}
+	repo.Close()
+
+	notDB := filepath.Join(dir, "not.sqlite3")
+	if err := os.WriteFile(notDB, []byte("not a database"), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	if err := RestoreDatabase(ctx, dsn, notDB); !errors.Is(err, errInvalidInput) {
+		t.Errorf("expected a file which isn't a database to be rejected, got %v", err)
+	}
+	if err := RestoreDatabase(ctx, dsn, backup); err != nil {
+		t.Fatalf("failed to restore database: %v", err)
+	}
+
+	repo, err = OpenAdminRepository(dsn)
+	if err != nil {
+		t.Fatalf("failed to open restored database: %v", err)
+	}
+	t.Cleanup(func() { repo.Close() })
+	got, err := repo.ListByCategory(ctx, "fashion")
+	if err != nil {
+		t.Fatalf("failed to list items: %v", err)
+	}
+	want := []Item{{ID: item.ID, Name: "jacket", Category: "fashion", ImageName: "default.jpg"}}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected items (-want +got):\n%s", diff)
+	}
+}
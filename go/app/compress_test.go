@@ -0,0 +1,155 @@
+package app
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		acceptEncoding string
+		want           string
+	}{
+		"ok: gzip":             {acceptEncoding: "gzip", want: "gzip"},
+		"ok: brotli preferred": {acceptEncoding: "gzip, deflate, br", want: "br"},
+		"ok: q-values":         {acceptEncoding: "br;q=0.5, gzip;q=0.8", want: "gzip"},
+		"ok: wildcard":         {acceptEncoding: "*", want: "br"},
+		"ok: brotli refused":   {acceptEncoding: "br;q=0, *", want: "gzip"},
+		"ng: none":             {acceptEncoding: "", want: ""},
+		"ng: unsupported":      {acceptEncoding: "deflate, identity", want: ""},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := negotiateEncoding(tt.acceptEncoding); got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestCompressMiddleware(t *testing.T) {
+	t.Parallel()
+
+	imageBytes, err := os.ReadFile("../images/default.jpg")
+	if err != nil {
+		t.Fatalf("failed to read image file: %v", err)
+	}
+	large := `{"items":[` + strings.Repeat(`{"name":"jacket","category":"fashion"},`, 100) + `{}]}`
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /large", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		// written in pieces to check the buffering
+		for _, chunk := range []string{large[:10], large[10:]} {
+			io.WriteString(w, chunk)
+		}
+	})
+	mux.HandleFunc("GET /small", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"message":"Hello, world!"}`)
+	})
+	mux.HandleFunc("GET /image", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/jpeg")
+		w.Write(imageBytes)
+	})
+	mux.HandleFunc("GET /empty", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	h := compressMiddleware(mux, compressMinSize)
+
+	type wants struct {
+		code     int
+		encoding string
+		vary     bool
+		body     string
+	}
+	cases := map[string]struct {
+		path           string
+		acceptEncoding string
+		wants
+	}{
+		"ok: gzip": {
+			path:           "/large",
+			acceptEncoding: "gzip",
+			wants:          wants{code: http.StatusOK, encoding: "gzip", vary: true, body: large},
+		},
+		"ok: brotli": {
+			path:           "/large",
+			acceptEncoding: "gzip, br",
+			wants:          wants{code: http.StatusOK, encoding: "br", vary: true, body: large},
+		},
+		"ok: not accepted": {
+			path:  "/large",
+			wants: wants{code: http.StatusOK, vary: true, body: large},
+		},
+		"ok: below the minimum size": {
+			path:           "/small",
+			acceptEncoding: "gzip",
+			wants:          wants{code: http.StatusOK, vary: true, body: `{"message":"Hello, world!"}`},
+		},
+		"ok: images are not compressed": {
+			path:           "/image",
+			acceptEncoding: "gzip",
+			wants:          wants{code: http.StatusOK, body: string(imageBytes)},
+		},
+		"ok: no content": {
+			path:           "/empty",
+			acceptEncoding: "gzip",
+			wants:          wants{code: http.StatusNoContent},
+		},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest("GET", tt.path, nil)
+			if tt.acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			}
+			rr := httptest.NewRecorder()
+			h.ServeHTTP(rr, req)
+
+			if rr.Code != tt.wants.code {
+				t.Errorf("expected status code %d, got %d", tt.wants.code, rr.Code)
+			}
+			if got := rr.Header().Get("Content-Encoding"); got != tt.wants.encoding {
+				t.Errorf("expected Content-Encoding %q, got %q", tt.wants.encoding, got)
+			}
+			if got := rr.Header().Get("Vary") == "Accept-Encoding"; got != tt.wants.vary {
+				t.Errorf("expected Vary: Accept-Encoding to be %v, got %q", tt.wants.vary, rr.Header().Get("Vary"))
+			}
+
+			var body io.Reader = rr.Body
+			switch tt.wants.encoding {
+			case "gzip":
+				gr, err := gzip.NewReader(rr.Body)
+				if err != nil {
+					t.Fatalf("failed to read gzip body: %v", err)
+				}
+				body = gr
+			case "br":
+				body = brotli.NewReader(rr.Body)
+			}
+			got, err := io.ReadAll(body)
+			if err != nil {
+				t.Fatalf("failed to read body: %v", err)
+			}
+			if string(got) != tt.wants.body {
+				t.Errorf("unexpected body: %.100s", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,431 @@
+package app
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// envPrefix is the prefix of the environment variables read by LoadConfig.
+const envPrefix = "MERCARI_"
+
+// Config is the configuration of the server.
+//
+// Each field can be set by a config file, an environment variable and a command line flag,
+// in increasing order of precedence. The names are derived from the yaml tag:
+// "image_dir" is read from the image_dir key of the file, $MERCARI_IMAGE_DIR and -image-dir.
+// Fields tagged with secret:"true" are redacted when the config is logged.
+// Durations must be positive, unless they are tagged with zero:"true" to allow zero.
+type Config struct {
+	Addr        string `yaml:"addr" usage:"address to listen on, e.g. :9000"`
+	DatabaseDSN string `yaml:"database_dsn" usage:"SQLite data source name" secret:"true"`
+	SchemaPath  string `yaml:"schema_path" usage:"path to the SQL file creating the tables"`
+	ImageDir    string `yaml:"image_dir" usage:"path to the directory storing images"`
+
+	CORSOrigins          []string      `yaml:"cors_origins" usage:"comma-separated list of origins allowed to call the API, e.g. https://*.example.com"`
+	CORSAllowCredentials bool          `yaml:"cors_allow_credentials" usage:"allow cross-origin requests with cookies"`
+	CORSAllowedHeaders   []string      `yaml:"cors_allowed_headers" usage:"comma-separated list of request headers allowed in cross-origin requests"`
+	CORSExposedHeaders   []string      `yaml:"cors_exposed_headers" usage:"comma-separated list of response headers readable by cross-origin scripts"`
+	CORSMaxAge           time.Duration `yaml:"cors_max_age" usage:"how long browsers may cache a preflight response"`
+
+	LogLevel  string `yaml:"log_level" usage:"minimum log level: debug, info, warn or error"`
+	LogFormat string `yaml:"log_format" usage:"log format: json or text"`
+
+	MaxImageBytes         int64 `yaml:"max_image_bytes" usage:"maximum size of an uploaded image in bytes"`
+	MaxImageDimension     int   `yaml:"max_image_dimension" usage:"maximum width and height of an uploaded image in pixels"`
+	SimilarImageThreshold int   `yaml:"similar_image_threshold" usage:"maximum Hamming distance of similar images"`
+	WarnSimilarImages     bool  `yaml:"warn_similar_images" usage:"warn when a new listing's photo looks like an existing one"`
+	MaxBodyBytes          int64 `yaml:"max_body_bytes" usage:"maximum size of a request body except image uploads in bytes"`
+
+	TrustProxyHeaders bool   `yaml:"trust_proxy_headers" usage:"trust X-Forwarded-* headers set by a reverse proxy in front of the server"`
+	PublicURL         string `yaml:"public_url" usage:"base URL of the API seen by clients, e.g. https://api.example.com; derived from each request if empty"`
+
+	RateLimitEnabled     bool `yaml:"rate_limit_enabled" usage:"limit the requests of each client"`
+	RateLimitWrite       int  `yaml:"rate_limit_write" usage:"POST requests per minute per client"`
+	RateLimitWriteBurst  int  `yaml:"rate_limit_write_burst" usage:"POST requests a client can make at once"`
+	RateLimitSearch      int  `yaml:"rate_limit_search" usage:"search requests per minute per client"`
+	RateLimitSearchBurst int  `yaml:"rate_limit_search_burst" usage:"search requests a client can make at once"`
+	RateLimitImage       int  `yaml:"rate_limit_image" usage:"image requests per minute per client"`
+	RateLimitImageBurst  int  `yaml:"rate_limit_image_burst" usage:"image requests a client can make at once"`
+
+	ReadHeaderTimeout  time.Duration `yaml:"read_header_timeout" usage:"deadline to read the request headers"`
+	ReadTimeout        time.Duration `yaml:"read_timeout" usage:"deadline to read the whole request including the body"`
+	WriteTimeout       time.Duration `yaml:"write_timeout" usage:"deadline to write the response"`
+	IdleTimeout        time.Duration `yaml:"idle_timeout" usage:"how long a keep-alive connection may stay idle"`
+	ShutdownTimeout    time.Duration `yaml:"shutdown_timeout" usage:"deadline to drain in-flight requests on shutdown"`
+	ShutdownDrainDelay time.Duration `yaml:"shutdown_drain_delay" usage:"how long /readyz fails on shutdown before new connections are refused; 0 to stop at once" zero:"true"`
+}
+
+// DefaultConfig returns the configuration used when nothing is specified.
+func DefaultConfig() Config {
+	return Config{
+		Addr:                  ":9000",
+		DatabaseDSN:           filepath.Join("db", "mercari.sqlite3"),
+		SchemaPath:            filepath.Join("db", "items.sql"),
+		ImageDir:              "images",
+		CORSOrigins:           []string{"http://localhost:3000"},
+		CORSAllowedHeaders:    []string{"Accept", "Content-Type", requestIDHeader},
+		CORSExposedHeaders:    []string{requestIDHeader, "Retry-After", "X-RateLimit-Limit", "X-RateLimit-Remaining", "X-RateLimit-Reset", "Deprecation", "Sunset", "Link"},
+		CORSMaxAge:            10 * time.Minute,
+		LogLevel:              "debug",
+		LogFormat:             "json",
+		MaxImageBytes:         defaultUploadLimits.maxBytes,
+		MaxImageDimension:     defaultUploadLimits.maxDimension,
+		SimilarImageThreshold: defaultSimilarImageThreshold,
+		MaxBodyBytes:          1 << 20, // 1MB
+		RateLimitEnabled:      true,
+		RateLimitWrite:        30,
+		RateLimitWriteBurst:   10,
+		RateLimitSearch:       120,
+		RateLimitSearchBurst:  30,
+		RateLimitImage:        600,
+		RateLimitImageBurst:   100,
+		ReadHeaderTimeout:     5 * time.Second,
+		ReadTimeout:           time.Minute,
+		WriteTimeout:          time.Minute,
+		IdleTimeout:           2 * time.Minute,
+		ShutdownTimeout:       defaultShutdownTimeout,
+		ShutdownDrainDelay:    defaultShutdownDrainDelay,
+	}
+}
+
+// LoadConfig loads the configuration from the command line arguments (without the program name),
+// the environment and an optional YAML config file given by -config or $MERCARI_CONFIG.
+// The precedence is flags > environment variables > config file > defaults.
+func LoadConfig(args []string, lookupEnv func(string) (string, bool)) (Config, error) {
+	cfg := DefaultConfig()
+
+	// collect the flags first and apply them last, since they take precedence
+	fs := flag.NewFlagSet("api", flag.ContinueOnError)
+	configPath := fs.String("config", "", "path to a YAML config file")
+	type flagValue struct{ name, value string }
+	var flagValues []flagValue
+	for _, f := range configFields() {
+		set := func(v string) error {
+			flagValues = append(flagValues, flagValue{name: f.flagName, value: v})
+			return nil
+		}
+		// boolean flags can be given without a value, e.g. -warn-similar-images
+		if f.isBool {
+			fs.BoolFunc(f.flagName, f.usage, set)
+		} else {
+			fs.Func(f.flagName, f.usage, set)
+		}
+	}
+	if err := fs.Parse(args); err != nil {
+		return Config{}, err
+	}
+	if fs.NArg() > 0 {
+		return Config{}, fmt.Errorf("unexpected arguments: %v", fs.Args())
+	}
+
+	// config file
+	path := *configPath
+	if path == "" {
+		path, _ = lookupEnv(envPrefix + "CONFIG")
+	}
+	if path != "" {
+		if err := cfg.loadFile(path); err != nil {
+			return Config{}, err
+		}
+	}
+
+	// environment variables
+	// PORT and FRONT_URL are still supported for existing deployments
+	if v, ok := lookupEnv("PORT"); ok && v != "" {
+		cfg.Addr = ":" + v
+	}
+	if v, ok := lookupEnv("FRONT_URL"); ok && v != "" {
+		cfg.CORSOrigins = splitList(v)
+	}
+	rv := reflect.ValueOf(&cfg).Elem()
+	for _, f := range configFields() {
+		v, ok := lookupEnv(f.envName)
+		if !ok {
+			continue
+		}
+		if err := setField(rv.Field(f.index), v); err != nil {
+			return Config{}, fmt.Errorf("invalid $%s: %w", f.envName, err)
+		}
+	}
+
+	// command line flags
+	fields := map[string]configField{}
+	for _, f := range configFields() {
+		fields[f.flagName] = f
+	}
+	for _, fv := range flagValues {
+		if err := setField(rv.Field(fields[fv.name].index), fv.value); err != nil {
+			return Config{}, fmt.Errorf("invalid -%s: %w", fv.name, err)
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return Config{}, err
+	}
+	return cfg, nil
+}
+
+// loadFile overwrites the configuration with the values in a YAML file.
+// Unknown keys are rejected so that typos don't go unnoticed.
+func (c *Config) loadFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open config file: %w", err)
+	}
+	defer f.Close()
+
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+	if err := dec.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// Validate checks the configuration and reports all problems at once.
+func (c Config) Validate() error {
+	var errs []error
+
+	if _, _, err := net.SplitHostPort(c.Addr); err != nil {
+		errs = append(errs, fmt.Errorf("addr: %w", err))
+	}
+	if c.DatabaseDSN == "" {
+		errs = append(errs, errors.New("database_dsn: must not be empty"))
+	}
+	if c.SchemaPath == "" {
+		errs = append(errs, errors.New("schema_path: must not be empty"))
+	}
+	if c.ImageDir == "" {
+		errs = append(errs, errors.New("image_dir: must not be empty"))
+	}
+	for _, origin := range c.CORSOrigins {
+		if origin == "*" {
+			// browsers refuse credentials with "*", and echoing any origin with credentials is unsafe
+			if c.CORSAllowCredentials {
+				errs = append(errs, errors.New("cors_origins: \"*\" can't be used with cors_allow_credentials"))
+			}
+			continue
+		}
+		if _, err := parseOriginPattern(origin); err != nil {
+			errs = append(errs, fmt.Errorf("cors_origins: %w", err))
+		}
+	}
+	if _, err := parseLogLevel(c.LogLevel); err != nil {
+		errs = append(errs, fmt.Errorf("log_level: %w", err))
+	}
+	if c.LogFormat != "json" && c.LogFormat != "text" {
+		errs = append(errs, fmt.Errorf("log_format: must be json or text, got %q", c.LogFormat))
+	}
+	if c.MaxImageBytes <= 0 {
+		errs = append(errs, errors.New("max_image_bytes: must be positive"))
+	}
+	if c.MaxImageDimension <= 0 {
+		errs = append(errs, errors.New("max_image_dimension: must be positive"))
+	}
+	if c.SimilarImageThreshold < 0 || c.SimilarImageThreshold > 64 {
+		errs = append(errs, errors.New("similar_image_threshold: must be between 0 and 64"))
+	}
+	if c.MaxBodyBytes <= 0 {
+		errs = append(errs, errors.New("max_body_bytes: must be positive"))
+	}
+	if c.PublicURL != "" {
+		u, err := url.Parse(c.PublicURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.RawQuery != "" || u.Fragment != "" {
+			errs = append(errs, fmt.Errorf("public_url: must be an absolute http(s) URL without query, got %q", c.PublicURL))
+		}
+	}
+	if c.RateLimitEnabled {
+		for _, v := range []struct {
+			name  string
+			value int
+		}{
+			{"rate_limit_write", c.RateLimitWrite},
+			{"rate_limit_write_burst", c.RateLimitWriteBurst},
+			{"rate_limit_search", c.RateLimitSearch},
+			{"rate_limit_search_burst", c.RateLimitSearchBurst},
+			{"rate_limit_image", c.RateLimitImage},
+			{"rate_limit_image_burst", c.RateLimitImageBurst},
+		} {
+			if v.value <= 0 {
+				errs = append(errs, fmt.Errorf("%s: must be positive", v.name))
+			}
+		}
+	}
+	for _, f := range configFields() {
+		d, ok := reflect.ValueOf(c).Field(f.index).Interface().(time.Duration)
+		switch {
+		case !ok:
+		case f.allowZero && d < 0:
+			errs = append(errs, fmt.Errorf("%s: must not be negative", f.yamlName))
+		case !f.allowZero && d <= 0:
+			errs = append(errs, fmt.Errorf("%s: must be positive", f.yamlName))
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid config: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// NewLogger returns a logger with the configured level and format.
+// Records logged with a request context carry the request ID.
+func (c Config) NewLogger() *slog.Logger {
+	level, _ := parseLogLevel(c.LogLevel)
+	opts := &slog.HandlerOptions{Level: level}
+	var h slog.Handler = slog.NewJSONHandler(os.Stderr, opts)
+	if c.LogFormat == "text" {
+		h = slog.NewTextHandler(os.Stderr, opts)
+	}
+	return slog.New(contextHandler{h})
+}
+
+// LogValue implements slog.LogValuer to log the effective configuration with secrets redacted.
+func (c Config) LogValue() slog.Value {
+	rv := reflect.ValueOf(c)
+	var attrs []slog.Attr
+	for _, f := range configFields() {
+		v := rv.Field(f.index).Interface()
+		if f.secret {
+			v = redact(fmt.Sprint(v))
+		}
+		if d, ok := v.(time.Duration); ok {
+			v = d.String()
+		}
+		attrs = append(attrs, slog.Any(f.yamlName, v))
+	}
+	return slog.GroupValue(attrs...)
+}
+
+// redactedValue replaces secrets in logs.
+const redactedValue = "REDACTED"
+
+// redact hides the credentials in a secret value.
+// For a DSN only the password and the query parameters that look like credentials are hidden,
+// so that the location of the database can still be seen.
+func redact(v string) string {
+	if v == "" {
+		return v
+	}
+	u, err := url.Parse(v)
+	if err != nil {
+		return redactedValue
+	}
+	if u.User != nil {
+		if _, ok := u.User.Password(); ok {
+			u.User = url.UserPassword(u.User.Username(), redactedValue)
+		}
+	}
+	q := u.Query()
+	for key := range q {
+		k := strings.ToLower(key)
+		if strings.Contains(k, "pass") || strings.Contains(k, "key") || strings.Contains(k, "token") || strings.Contains(k, "secret") {
+			q.Set(key, redactedValue)
+		}
+	}
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// configField describes how a field of Config is read.
+type configField struct {
+	index    int
+	yamlName string
+	envName  string
+	flagName string
+	usage    string
+	secret   bool
+	isBool   bool
+	// allowZero allows a duration to be zero, e.g. a delay which can be disabled.
+	allowZero bool
+}
+
+// configFields lists the fields of Config with their names derived from the yaml tag.
+func configFields() []configField {
+	t := reflect.TypeOf(Config{})
+	fields := make([]configField, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		name := sf.Tag.Get("yaml")
+		fields = append(fields, configField{
+			index:     i,
+			yamlName:  name,
+			envName:   envPrefix + strings.ToUpper(name),
+			flagName:  strings.ReplaceAll(name, "_", "-"),
+			usage:     sf.Tag.Get("usage"),
+			secret:    sf.Tag.Get("secret") == "true",
+			isBool:    sf.Type.Kind() == reflect.Bool,
+			allowZero: sf.Tag.Get("zero") == "true",
+		})
+	}
+	return fields
+}
+
+// setField parses s according to the type of v and sets it.
+func setField(v reflect.Value, s string) error {
+	switch v.Interface().(type) {
+	case time.Duration:
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	case []string:
+		v.Set(reflect.ValueOf(splitList(s)))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+// splitList splits a comma-separated list, ignoring empty elements.
+func splitList(s string) []string {
+	list := []string{}
+	for _, e := range strings.Split(s, ",") {
+		if e = strings.TrimSpace(e); e != "" {
+			list = append(list, e)
+		}
+	}
+	return list
+}
+
+// parseLogLevel parses a log level name.
+func parseLogLevel(s string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(s)); err != nil {
+		return 0, fmt.Errorf("unknown log level %q", s)
+	}
+	return level, nil
+}
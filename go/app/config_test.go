@@ -0,0 +1,176 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestLoadConfig(t *testing.T) {
+	t.Parallel()
+
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+	err := os.WriteFile(configFile, []byte(`
+addr: ":8000"
+image_dir: /var/images
+log_level: info
+shutdown_timeout: 30s
+`), 0644)
+	if err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+
+	cases := map[string]struct {
+		args    []string
+		env     map[string]string
+		want    func(c *Config)
+		wantErr string
+	}{
+		"ok: defaults": {
+			want: func(c *Config) {},
+		},
+		"ok: config file": {
+			args: []string{"-config", configFile},
+			want: func(c *Config) {
+				c.Addr = ":8000"
+				c.ImageDir = "/var/images"
+				c.LogLevel = "info"
+				c.ShutdownTimeout = 30 * time.Second
+			},
+		},
+		"ok: environment variables override the config file": {
+			env: map[string]string{
+				"MERCARI_CONFIG":       configFile,
+				"MERCARI_IMAGE_DIR":    "/srv/images",
+				"MERCARI_CORS_ORIGINS": "https://a.example.com, https://*.b.example.com",
+				"FRONT_URL":            "https://ignored.example.com",
+			},
+			want: func(c *Config) {
+				c.Addr = ":8000"
+				c.ImageDir = "/srv/images"
+				c.LogLevel = "info"
+				c.ShutdownTimeout = 30 * time.Second
+				c.CORSOrigins = []string{"https://a.example.com", "https://*.b.example.com"}
+			},
+		},
+		"ok: flags override environment variables": {
+			args: []string{"-config", configFile, "-image-dir", "/tmp/images", "-warn-similar-images", "-max-image-bytes", "1024"},
+			env:  map[string]string{"MERCARI_IMAGE_DIR": "/srv/images"},
+			want: func(c *Config) {
+				c.Addr = ":8000"
+				c.ImageDir = "/tmp/images"
+				c.LogLevel = "info"
+				c.ShutdownTimeout = 30 * time.Second
+				c.WarnSimilarImages = true
+				c.MaxImageBytes = 1024
+			},
+		},
+		"ok: legacy environment variables": {
+			env: map[string]string{"PORT": "9001", "FRONT_URL": "http://localhost:3001"},
+			want: func(c *Config) {
+				c.Addr = ":9001"
+				c.CORSOrigins = []string{"http://localhost:3001"}
+			},
+		},
+		"ok: zero drain delay stops at once": {
+			args: []string{"-shutdown-drain-delay", "0s"},
+			want: func(c *Config) {
+				c.ShutdownDrainDelay = 0
+			},
+		},
+		"ng: negative drain delay": {
+			args:    []string{"-shutdown-drain-delay", "-1s"},
+			wantErr: "shutdown_drain_delay: must not be negative",
+		},
+		"ng: zero shutdown timeout": {
+			args:    []string{"-shutdown-timeout", "0s"},
+			wantErr: "shutdown_timeout: must be positive",
+		},
+		"ng: all validation errors are reported": {
+			args:    []string{"-log-format", "xml", "-max-image-bytes", "0", "-cors-origins", "localhost:3000"},
+			wantErr: "cors_origins: invalid origin \"localhost:3000\"\nlog_format: must be json or text, got \"xml\"\nmax_image_bytes: must be positive",
+		},
+		"ng: any origin with credentials": {
+			args:    []string{"-cors-origins", "*", "-cors-allow-credentials"},
+			wantErr: "cors_origins: \"*\" can't be used with cors_allow_credentials",
+		},
+		"ng: relative public URL": {
+			args:    []string{"-public-url", "api.example.com"},
+			wantErr: "public_url: must be an absolute http(s) URL without query, got \"api.example.com\"",
+		},
+		"ng: invalid environment variable": {
+			env:     map[string]string{"MERCARI_SHUTDOWN_TIMEOUT": "10"},
+			wantErr: "invalid $MERCARI_SHUTDOWN_TIMEOUT",
+		},
+		"ng: unknown key in the config file": {
+			args:    []string{"-config", writeFile(t, "port: 9000\n")},
+			wantErr: "field port not found",
+		},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			lookupEnv := func(key string) (string, bool) {
+				v, ok := tt.env[key]
+				return v, ok
+			}
+			got, err := LoadConfig(tt.args, lookupEnv)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("expected an error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			want := DefaultConfig()
+			tt.want(&want)
+			if diff := cmp.Diff(want, got); diff != "" {
+				t.Errorf("unexpected config (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestRedact(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		in   string
+		want string
+	}{
+		"ok: plain path":    {in: "db/mercari.sqlite3", want: "db/mercari.sqlite3"},
+		"ok: empty":         {in: "", want: ""},
+		"ok: password":      {in: "file:db/mercari.sqlite3?_auth_pass=hunter2&cache=shared", want: "file:db/mercari.sqlite3?_auth_pass=REDACTED&cache=shared"},
+		"ok: user password": {in: "postgres://user:hunter2@db:5432/mercari", want: "postgres://user:REDACTED@db:5432/mercari"},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := redact(tt.in); got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+// writeFile writes content to a temporary file and returns its path.
+func writeFile(t *testing.T, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	return path
+}
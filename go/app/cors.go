@@ -0,0 +1,161 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// corsPolicy decides which cross-origin requests are allowed.
+type corsPolicy struct {
+	origins     []originPattern
+	allowAll    bool // whether "*" is in the origins
+	methods     []string
+	headers     []string // request headers allowed in addition to the CORS-safelisted ones
+	exposed     []string // response headers readable by scripts
+	credentials bool
+	maxAge      time.Duration
+}
+
+// newCORSPolicy creates a corsPolicy from the configuration. methods are the methods the routes accept.
+func newCORSPolicy(cfg Config, methods []string) (corsPolicy, error) {
+	p := corsPolicy{
+		methods:     methods,
+		headers:     cfg.CORSAllowedHeaders,
+		exposed:     cfg.CORSExposedHeaders,
+		credentials: cfg.CORSAllowCredentials,
+		maxAge:      cfg.CORSMaxAge,
+	}
+	for _, origin := range cfg.CORSOrigins {
+		if origin == "*" {
+			p.allowAll = true
+			continue
+		}
+		pattern, err := parseOriginPattern(origin)
+		if err != nil {
+			return corsPolicy{}, err
+		}
+		p.origins = append(p.origins, pattern)
+	}
+	return p, nil
+}
+
+// originPattern is an allowed origin such as "https://example.com" or "https://*.example.com".
+type originPattern struct {
+	scheme string
+	host   string // host and port; the domain without "*." for a wildcard
+	// wildcard matches any subdomain of host, but not host itself
+	wildcard bool
+}
+
+// parseOriginPattern parses an origin which may start with a "*." wildcard subdomain.
+func parseOriginPattern(s string) (originPattern, error) {
+	scheme, rest, ok := strings.Cut(s, "://")
+	host, wildcard := strings.CutPrefix(rest, "*.")
+	if !ok || (scheme != "http" && scheme != "https") {
+		return originPattern{}, fmt.Errorf("invalid origin %q", s)
+	}
+	// check the rest as a plain origin
+	u, err := url.Parse(scheme + "://" + host)
+	if err != nil || u.Host != host || host == "" || strings.Contains(host, "*") {
+		return originPattern{}, fmt.Errorf("invalid origin %q", s)
+	}
+	return originPattern{scheme: scheme, host: strings.ToLower(host), wildcard: wildcard}, nil
+}
+
+func (o originPattern) match(scheme, host string) bool {
+	if scheme != o.scheme {
+		return false
+	}
+	if o.wildcard {
+		return strings.HasSuffix(host, "."+o.host)
+	}
+	return host == o.host
+}
+
+// allowOrigin reports whether requests from origin are allowed.
+func (p corsPolicy) allowOrigin(origin string) bool {
+	if p.allowAll {
+		return true
+	}
+	scheme, host, ok := strings.Cut(origin, "://")
+	if !ok {
+		return false
+	}
+	host = strings.ToLower(host)
+	return slices.ContainsFunc(p.origins, func(o originPattern) bool { return o.match(scheme, host) })
+}
+
+// allowHeaders reports whether all headers in the Access-Control-Request-Headers of a preflight are allowed.
+func (p corsPolicy) allowHeaders(requested string) bool {
+	for _, h := range strings.Split(requested, ",") {
+		h = strings.TrimSpace(h)
+		if h == "" {
+			continue
+		}
+		if !slices.ContainsFunc(p.headers, func(allowed string) bool { return strings.EqualFold(allowed, h) }) {
+			return false
+		}
+	}
+	return true
+}
+
+// errCORSRejected is returned for preflight requests which aren't allowed.
+var errCORSRejected = errors.New("cross-origin request is not allowed")
+
+// corsMiddleware implements CORS. The allowed origin is echoed back rather than "*",
+// so that responses vary by Origin and can be used with credentials.
+// Preflight requests which aren't allowed are rejected with 403 instead of letting the browser find out.
+func corsMiddleware(next http.Handler, p corsPolicy) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Origin")
+		origin := r.Header.Get("Origin")
+		preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+		if origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !p.allowOrigin(origin) {
+			if preflight {
+				writeError(w, r, forbidden(errCORSRejected))
+				return
+			}
+			// without the CORS headers, the browser doesn't let the script read the response
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		if p.credentials {
+			w.Header().Set("Access-Control-Allow-Credentials", "true")
+		}
+
+		if !preflight {
+			if len(p.exposed) > 0 {
+				w.Header().Set("Access-Control-Expose-Headers", strings.Join(p.exposed, ", "))
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Add("Vary", "Access-Control-Request-Method")
+		w.Header().Add("Vary", "Access-Control-Request-Headers")
+		if !slices.Contains(p.methods, r.Header.Get("Access-Control-Request-Method")) ||
+			!p.allowHeaders(r.Header.Get("Access-Control-Request-Headers")) {
+			writeError(w, r, forbidden(errCORSRejected))
+			return
+		}
+		w.Header().Set("Access-Control-Allow-Methods", strings.Join(p.methods, ", "))
+		if len(p.headers) > 0 {
+			w.Header().Set("Access-Control-Allow-Headers", strings.Join(p.headers, ", "))
+		}
+		w.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(p.maxAge.Seconds())))
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
@@ -0,0 +1,184 @@
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestCORSMiddleware(t *testing.T) {
+	t.Parallel()
+
+	cfg := DefaultConfig()
+	cfg.CORSOrigins = []string{"https://mercari.example.com", "https://*.preview.example.com"}
+	cfg.CORSAllowCredentials = true
+	cfg.CORSMaxAge = 5 * time.Minute
+	cfg.CORSExposedHeaders = []string{"X-Request-ID"}
+	p, err := newCORSPolicy(cfg, []string{"GET", "HEAD", "POST"})
+	if err != nil {
+		t.Fatalf("failed to create CORS policy: %v", err)
+	}
+	h := corsMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}), p)
+
+	type wants struct {
+		code    int
+		headers map[string]string // "" means the header must be absent
+	}
+	cases := map[string]struct {
+		method  string
+		headers map[string]string
+		wants
+	}{
+		"ok: same-origin request": {
+			method: "GET",
+			wants: wants{
+				code:    http.StatusTeapot,
+				headers: map[string]string{"Access-Control-Allow-Origin": ""},
+			},
+		},
+		"ok: allowed origin": {
+			method:  "GET",
+			headers: map[string]string{"Origin": "https://mercari.example.com"},
+			wants: wants{
+				code: http.StatusTeapot,
+				headers: map[string]string{
+					"Access-Control-Allow-Origin":      "https://mercari.example.com",
+					"Access-Control-Allow-Credentials": "true",
+					"Access-Control-Expose-Headers":    "X-Request-ID",
+					"Vary":                             "Origin",
+				},
+			},
+		},
+		"ok: wildcard subdomain": {
+			method:  "GET",
+			headers: map[string]string{"Origin": "https://pr-42.preview.example.com"},
+			wants: wants{
+				code:    http.StatusTeapot,
+				headers: map[string]string{"Access-Control-Allow-Origin": "https://pr-42.preview.example.com"},
+			},
+		},
+		"ng: wildcard doesn't match the bare domain": {
+			method:  "GET",
+			headers: map[string]string{"Origin": "https://preview.example.com"},
+			wants: wants{
+				code:    http.StatusTeapot,
+				headers: map[string]string{"Access-Control-Allow-Origin": ""},
+			},
+		},
+		"ng: wildcard doesn't match a lookalike domain": {
+			method:  "GET",
+			headers: map[string]string{"Origin": "https://evil-preview.example.com"},
+			wants: wants{
+				code:    http.StatusTeapot,
+				headers: map[string]string{"Access-Control-Allow-Origin": ""},
+			},
+		},
+		"ok: preflight": {
+			method: "OPTIONS",
+			headers: map[string]string{
+				"Origin":                         "https://mercari.example.com",
+				"Access-Control-Request-Method":  "POST",
+				"Access-Control-Request-Headers": "content-type, x-request-id",
+			},
+			wants: wants{
+				code: http.StatusNoContent,
+				headers: map[string]string{
+					"Access-Control-Allow-Origin":  "https://mercari.example.com",
+					"Access-Control-Allow-Methods": "GET, HEAD, POST",
+					"Access-Control-Allow-Headers": "Accept, Content-Type, X-Request-ID",
+					"Access-Control-Max-Age":       "300",
+				},
+			},
+		},
+		"ng: preflight from a disallowed origin": {
+			method: "OPTIONS",
+			headers: map[string]string{
+				"Origin":                        "https://evil.example.com",
+				"Access-Control-Request-Method": "POST",
+			},
+			wants: wants{
+				code:    http.StatusForbidden,
+				headers: map[string]string{"Access-Control-Allow-Origin": ""},
+			},
+		},
+		"ng: preflight with a disallowed method": {
+			method: "OPTIONS",
+			headers: map[string]string{
+				"Origin":                        "https://mercari.example.com",
+				"Access-Control-Request-Method": "DELETE",
+			},
+			wants: wants{code: http.StatusForbidden},
+		},
+		"ng: preflight with a disallowed header": {
+			method: "OPTIONS",
+			headers: map[string]string{
+				"Origin":                         "https://mercari.example.com",
+				"Access-Control-Request-Method":  "POST",
+				"Access-Control-Request-Headers": "x-secret",
+			},
+			wants: wants{code: http.StatusForbidden},
+		},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest(tt.method, "/items", nil)
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			rr := httptest.NewRecorder()
+			h.ServeHTTP(rr, req)
+
+			if rr.Code != tt.wants.code {
+				t.Errorf("expected status code %d, got %d", tt.wants.code, rr.Code)
+			}
+			got := map[string]string{}
+			for k := range tt.wants.headers {
+				got[k] = rr.Header().Get(k)
+			}
+			if diff := cmp.Diff(tt.wants.headers, got, cmpopts.EquateEmpty()); diff != "" {
+				t.Errorf("unexpected headers (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestParseOriginPattern(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		origin  string
+		want    originPattern
+		wantErr bool
+	}{
+		"ok: plain":        {origin: "https://example.com", want: originPattern{scheme: "https", host: "example.com"}},
+		"ok: port":         {origin: "http://localhost:3000", want: originPattern{scheme: "http", host: "localhost:3000"}},
+		"ok: wildcard":     {origin: "https://*.Example.com", want: originPattern{scheme: "https", host: "example.com", wildcard: true}},
+		"ng: no scheme":    {origin: "localhost:3000", wantErr: true},
+		"ng: path":         {origin: "https://example.com/app", wantErr: true},
+		"ng: inner star":   {origin: "https://a.*.example.com", wantErr: true},
+		"ng: other scheme": {origin: "ftp://example.com", wantErr: true},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := parseOriginPattern(tt.origin)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error=%v, got %v", tt.wantErr, err)
+			}
+			if diff := cmp.Diff(tt.want, got, cmp.AllowUnexported(originPattern{})); diff != "" {
+				t.Errorf("unexpected pattern (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
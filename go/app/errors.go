@@ -0,0 +1,120 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Error codes of APIError. Unlike messages, clients can rely on them not to change.
+const (
+	codeBadRequest       = "bad_request"
+	codeValidation       = "validation_failed"
+	codeForbidden        = "forbidden"
+	codeNotFound         = "not_found"
+	codeMethodNotAllowed = "method_not_allowed"
+	codePayloadTooLarge  = "payload_too_large"
+	codeRateLimited      = "rate_limited"
+	codeInternal         = "internal_error"
+)
+
+// APIError is an error returned to clients as JSON.
+type APIError struct {
+	Status    int          `json:"-"`
+	Code      string       `json:"code"`
+	Message   string       `json:"message"`
+	Details   []FieldError `json:"details,omitempty"`
+	RequestID string       `json:"request_id,omitempty"`
+
+	// err is the cause of the error. It is never sent to clients.
+	err error
+}
+
+// FieldError tells which field of a request is invalid and why.
+type FieldError struct {
+	Field   string `json:"field,omitempty"` // empty if the error isn't about a single field
+	Message string `json:"message"`
+}
+
+func (e *APIError) Error() string {
+	return e.Message
+}
+
+func (e *APIError) Unwrap() error {
+	return e.err
+}
+
+type ErrorResponse struct {
+	Error *APIError `json:"error"`
+}
+
+// badRequest returns a 400 error whose message is the message of err.
+// Only use it for errors which are safe to show to clients, e.g. from parsing requests.
+// If err already is an APIError, e.g. a validation error, it is returned as is.
+func badRequest(err error) *APIError {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr
+	}
+	return &APIError{Status: http.StatusBadRequest, Code: codeBadRequest, Message: err.Error(), err: err}
+}
+
+// forbidden returns a 403 error whose message is the message of err.
+func forbidden(err error) *APIError {
+	return &APIError{Status: http.StatusForbidden, Code: codeForbidden, Message: err.Error(), err: err}
+}
+
+// notFound returns a 404 error whose message is the message of err.
+func notFound(err error) *APIError {
+	return &APIError{Status: http.StatusNotFound, Code: codeNotFound, Message: err.Error(), err: err}
+}
+
+// tooManyRequests returns a 429 error whose message is the message of err.
+func tooManyRequests(err error) *APIError {
+	return &APIError{Status: http.StatusTooManyRequests, Code: codeRateLimited, Message: err.Error(), err: err}
+}
+
+// toAPIError maps err to the error returned to clients.
+// Known errors are mapped to their statuses here, so that handlers don't need to.
+// Any other error is a 500 whose message doesn't tell anything about the internals.
+func toAPIError(err error) *APIError {
+	var maxBytesErr *http.MaxBytesError
+	var apiErr *APIError
+	switch {
+	case errors.As(err, &maxBytesErr):
+		return &APIError{
+			Status:  http.StatusRequestEntityTooLarge,
+			Code:    codePayloadTooLarge,
+			Message: fmt.Sprintf("request body exceeds the maximum size of %d bytes", maxBytesErr.Limit),
+			err:     err,
+		}
+	case errors.Is(err, errImageTooLarge):
+		return &APIError{Status: http.StatusRequestEntityTooLarge, Code: codePayloadTooLarge, Message: err.Error(), err: err}
+	case errors.Is(err, errInvalidImage):
+		return &APIError{Status: http.StatusBadRequest, Code: codeBadRequest, Message: err.Error(), err: err}
+	case errors.Is(err, errInvalidInput):
+		return &APIError{Status: http.StatusBadRequest, Code: codeBadRequest, Message: "invalid input", err: err}
+	case errors.Is(err, errItemNotFound):
+		return &APIError{Status: http.StatusNotFound, Code: codeNotFound, Message: "item not found", err: err}
+	case errors.Is(err, errImageNotFound):
+		return &APIError{Status: http.StatusNotFound, Code: codeNotFound, Message: "image not found", err: err}
+	case errors.As(err, &apiErr):
+		// copy it so that setting the request ID doesn't modify a shared error
+		copied := *apiErr
+		return &copied
+	default:
+		return &APIError{Status: http.StatusInternalServerError, Code: codeInternal, Message: "internal server error", err: err}
+	}
+}
+
+// writeError writes err as an ErrorResponse with the request ID of r.
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	apiErr := toAPIError(err)
+	apiErr.RequestID = requestIDFromContext(r.Context())
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(apiErr.Status)
+	json.NewEncoder(w).Encode(ErrorResponse{Error: apiErr})
+}
@@ -0,0 +1,74 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestWriteError(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		err  error
+		want ErrorResponse
+		code int
+	}{
+		"ng: bad request": {
+			err:  badRequest(errors.New("name is required")),
+			code: http.StatusBadRequest,
+			want: ErrorResponse{Error: &APIError{Code: codeBadRequest, Message: "name is required", RequestID: "req-1"}},
+		},
+		"ng: item not found": {
+			err:  fmt.Errorf("failed to get item: %w", errItemNotFound),
+			code: http.StatusNotFound,
+			want: ErrorResponse{Error: &APIError{Code: codeNotFound, Message: "item not found", RequestID: "req-1"}},
+		},
+		"ng: invalid input": {
+			err:  errInvalidInput,
+			code: http.StatusBadRequest,
+			want: ErrorResponse{Error: &APIError{Code: codeBadRequest, Message: "invalid input", RequestID: "req-1"}},
+		},
+		"ng: body too large while parsing": {
+			err:  badRequest(fmt.Errorf("failed to read form: %w", &http.MaxBytesError{Limit: 16})),
+			code: http.StatusRequestEntityTooLarge,
+			want: ErrorResponse{Error: &APIError{Code: codePayloadTooLarge, Message: "request body exceeds the maximum size of 16 bytes", RequestID: "req-1"}},
+		},
+		"ng: internal error is not exposed": {
+			err:  errors.New("failed to query items: database is locked"),
+			code: http.StatusInternalServerError,
+			want: ErrorResponse{Error: &APIError{Code: codeInternal, Message: "internal server error", RequestID: "req-1"}},
+		},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest("GET", "/", nil)
+			req = req.WithContext(withRequestID(req.Context(), "req-1"))
+			rr := httptest.NewRecorder()
+			writeError(rr, req, tt.err)
+
+			if rr.Code != tt.code {
+				t.Errorf("expected status code %d, got %d", tt.code, rr.Code)
+			}
+			if ct := rr.Header().Get("Content-Type"); ct != "application/json" {
+				t.Errorf("expected Content-Type application/json, got %q", ct)
+			}
+			var got ErrorResponse
+			if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+				t.Fatalf("failed to parse response body: %v", err)
+			}
+			if diff := cmp.Diff(tt.want, got, cmpopts.IgnoreUnexported(APIError{})); diff != "" {
+				t.Errorf("unexpected response (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,229 @@
+package app
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// exportWriteTimeout is the deadline to write an export, which takes longer than other responses
+// for a large catalog. It replaces the server's write timeout for GET /items/export.
+const exportWriteTimeout = 10 * time.Minute
+
+// Export formats. Imports are read in the same csv and jsonl formats.
+const (
+	exportFormatCSV   = importFormatCSV
+	exportFormatJSONL = importFormatJSONL
+	exportFormatJSON  = "json"
+)
+
+// exportCSVHeader are the columns of a CSV export. The links are left out, since they are derived from the ID.
+var exportCSVHeader = []string{"id", "name", "category", "image_name", "image_url"}
+
+type ExportItemsRequest struct {
+	Format   string `query:"format"`                                           // csv, jsonl or json; json if empty
+	Keyword  string `query:"keyword" validate:"trim,nfc,max=100,charset=text"` // only the items whose name contains it if set
+	Category string `query:"category" validate:"trim,nfc,max=50,charset=text"` // only the items in this category if set
+}
+
+// parseExportItemsRequest parses and validates the request to export items.
+func parseExportItemsRequest(r *http.Request) (*ExportItemsRequest, error) {
+	req := &ExportItemsRequest{
+		Format:   r.URL.Query().Get("format"),
+		Keyword:  r.URL.Query().Get("keyword"),
+		Category: r.URL.Query().Get("category"),
+	}
+
+	violations := validateStruct(req)
+	switch req.Format {
+	case "":
+		req.Format = exportFormatJSON
+	case exportFormatCSV, exportFormatJSONL, exportFormatJSON:
+	default:
+		violations = append(violations, FieldError{Field: "format", Message: "must be csv, jsonl or json"})
+	}
+	if len(violations) > 0 {
+		return nil, validationError(violations)
+	}
+
+	return req, nil
+}
+
+// itemEncoder writes the items of an export one by one.
+type itemEncoder interface {
+	encode(item ItemResource) error
+	// close writes the end of the export.
+	close() error
+}
+
+// newItemEncoder returns the encoder of format and the content type of its output.
+func newItemEncoder(w io.Writer, format string) (itemEncoder, string) {
+	switch format {
+	case exportFormatCSV:
+		return &csvItemEncoder{w: csv.NewWriter(w)}, "text/csv; charset=utf-8"
+	case exportFormatJSONL:
+		return &jsonlItemEncoder{enc: json.NewEncoder(w)}, "application/x-ndjson"
+	default:
+		return &jsonItemEncoder{w: w}, "application/json"
+	}
+}
+
+type csvItemEncoder struct {
+	w             *csv.Writer
+	headerWritten bool
+}
+
+func (e *csvItemEncoder) writeHeader() error {
+	if e.headerWritten {
+		return nil
+	}
+	e.headerWritten = true
+	return e.w.Write(exportCSVHeader)
+}
+
+func (e *csvItemEncoder) encode(item ItemResource) error {
+	if err := e.writeHeader(); err != nil {
+		return err
+	}
+	return e.w.Write([]string{
+		strconv.Itoa(item.ID), escapeCSVFormula(item.Name), escapeCSVFormula(item.Category),
+		escapeCSVFormula(item.ImageName), escapeCSVFormula(item.ImageURL),
+	})
+}
+
+// csvFormulaPrefixes are the first characters which make spreadsheets evaluate a cell as a formula.
+const csvFormulaPrefixes = "=+-@\t\r"
+
+// escapeCSVFormula prefixes a cell which a spreadsheet would evaluate as a formula with ',
+// so that an item named e.g. "=HYPERLINK(...)" is shown as text. unescapeCSVFormula reverts it on import.
+func escapeCSVFormula(s string) string {
+	if s != "" && strings.ContainsRune(csvFormulaPrefixes, rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+// unescapeCSVFormula removes the ' added by escapeCSVFormula.
+func unescapeCSVFormula(s string) string {
+	if len(s) > 1 && s[0] == '\'' && strings.ContainsRune(csvFormulaPrefixes, rune(s[1])) {
+		return s[1:]
+	}
+	return s
+}
+
+func (e *csvItemEncoder) close() error {
+	// an empty export still has the header
+	if err := e.writeHeader(); err != nil {
+		return err
+	}
+	e.w.Flush()
+	return e.w.Error()
+}
+
+type jsonlItemEncoder struct {
+	enc *json.Encoder
+}
+
+func (e *jsonlItemEncoder) encode(item ItemResource) error {
+	return e.enc.Encode(item)
+}
+
+func (e *jsonlItemEncoder) close() error {
+	return nil
+}
+
+// jsonItemEncoder writes the items as GetItemsResponse, one element of the array at a time.
+type jsonItemEncoder struct {
+	w     io.Writer
+	count int
+}
+
+func (e *jsonItemEncoder) encode(item ItemResource) error {
+	b, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+	sep := ","
+	if e.count == 0 {
+		sep = `{"items":[`
+	}
+	e.count++
+	if _, err := io.WriteString(e.w, sep); err != nil {
+		return err
+	}
+	_, err = e.w.Write(b)
+	return err
+}
+
+func (e *jsonItemEncoder) close() error {
+	end := "]}\n"
+	if e.count == 0 {
+		end = `{"items":[]}` + "\n"
+	}
+	_, err := io.WriteString(e.w, end)
+	return err
+}
+
+// startedWriter tells whether anything has been written to the response.
+type startedWriter struct {
+	w       io.Writer
+	started bool
+}
+
+func (sw *startedWriter) Write(p []byte) (int, error) {
+	sw.started = true
+	return sw.w.Write(p)
+}
+
+// ExportItems is a handler to export items for GET /items/export .
+// The items are streamed from the database as they are read, so the whole catalog is never held in memory.
+func (s *Handlers) ExportItems(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	req, err := parseExportItemsRequest(r)
+	if err != nil {
+		slog.WarnContext(ctx, "failed to parse export items request: ", "error", err)
+		writeError(w, r, badRequest(err))
+		return
+	}
+
+	// the default write timeout is meant for small responses; an error means the writer doesn't support deadlines
+	_ = http.NewResponseController(w).SetWriteDeadline(time.Now().Add(exportWriteTimeout))
+
+	sw := &startedWriter{w: w}
+	buf := bufio.NewWriter(sw)
+	enc, contentType := newItemEncoder(buf, req.Format)
+	w.Header().Set("Content-Type", contentType)
+	if req.Format != exportFormatJSON {
+		w.Header().Set("Content-Disposition", `attachment; filename="items.`+req.Format+`"`)
+	}
+
+	urls := s.itemURLs(r)
+	count := 0
+	err = s.itemRepo.Stream(ctx, req.Keyword, req.Category, func(item Item) error {
+		count++
+		return enc.encode(newItemResource(item, urls, nil))
+	})
+	if err == nil {
+		if err = enc.close(); err == nil {
+			err = buf.Flush()
+		}
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to export items: ", "error", err)
+		if !sw.started {
+			w.Header().Del("Content-Disposition")
+			writeError(w, r, err)
+			return
+		}
+		// the status has been sent, so aborting the connection is the only way to tell the client the export is broken
+		panic(http.ErrAbortHandler)
+	}
+	slog.InfoContext(ctx, "exported items", "format", req.Format, "count", count)
+}
@@ -0,0 +1,157 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.uber.org/mock/gomock"
+)
+
+func TestExportItems(t *testing.T) {
+	t.Parallel()
+
+	items := []Item{
+		{ID: 1, Name: "jacket", Category: "fashion", ImageName: "default.jpg"},
+		{ID: 2, Name: "mug, large", Category: "kitchen", ImageName: "mug.jpg"},
+	}
+	// stream mocks Stream by calling fn with items
+	stream := func(items []Item) func(context.Context, string, string, func(Item) error) error {
+		return func(_ context.Context, _, _ string, fn func(Item) error) error {
+			for _, item := range items {
+				if err := fn(item); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	}
+
+	type wants struct {
+		code        int
+		contentType string
+		body        string
+	}
+	cases := map[string]struct {
+		query    string
+		injector func(m *MockItemRepository)
+		wants
+	}{
+		"ok: csv": {
+			query: "?format=csv&keyword=+jack",
+			injector: func(m *MockItemRepository) {
+				m.EXPECT().Stream(gomock.Any(), "jack", "", gomock.Any()).DoAndReturn(stream(items))
+			},
+			wants: wants{
+				code:        http.StatusOK,
+				contentType: "text/csv; charset=utf-8",
+				body: "id,name,category,image_name,image_url\n" +
+					"1,jacket,fashion,default.jpg,http://example.com/v1/images/default.jpg\n" +
+					"2,\"mug, large\",kitchen,mug.jpg,http://example.com/v1/images/mug.jpg\n",
+			},
+		},
+		"ok: csv cells which spreadsheets evaluate are escaped": {
+			query: "?format=csv&category=fashion",
+			injector: func(m *MockItemRepository) {
+				m.EXPECT().Stream(gomock.Any(), "", "fashion", gomock.Any()).DoAndReturn(stream([]Item{
+					{ID: 3, Name: "=HYPERLINK(\"http://evil.example.com\")", Category: "@fashion", ImageName: "default.jpg"},
+					{ID: 4, Name: "-5% jacket", Category: "+fashion", ImageName: "default.jpg"},
+				}))
+			},
+			wants: wants{
+				code:        http.StatusOK,
+				contentType: "text/csv; charset=utf-8",
+				body: "id,name,category,image_name,image_url\n" +
+					"3,\"'=HYPERLINK(\"\"http://evil.example.com\"\")\",'@fashion,default.jpg,http://example.com/v1/images/default.jpg\n" +
+					"4,'-5% jacket,'+fashion,default.jpg,http://example.com/v1/images/default.jpg\n",
+			},
+		},
+		"ok: csv without items still has the header": {
+			query: "?format=csv",
+			injector: func(m *MockItemRepository) {
+				m.EXPECT().Stream(gomock.Any(), "", "", gomock.Any()).DoAndReturn(stream(nil))
+			},
+			wants: wants{
+				code:        http.StatusOK,
+				contentType: "text/csv; charset=utf-8",
+				body:        "id,name,category,image_name,image_url\n",
+			},
+		},
+		"ok: jsonl": {
+			query: "?format=jsonl",
+			injector: func(m *MockItemRepository) {
+				m.EXPECT().Stream(gomock.Any(), "", "", gomock.Any()).DoAndReturn(stream(items[:1]))
+			},
+			wants: wants{
+				code:        http.StatusOK,
+				contentType: "application/x-ndjson",
+				body: `{"id":1,"name":"jacket","category":"fashion","image_name":"default.jpg","image_url":"http://example.com/v1/images/default.jpg",` +
+					`"links":{"self":"http://example.com/v1/items/1","category":"http://example.com/v1/items?category=fashion"}}` + "\n",
+			},
+		},
+		"ok: json by default": {
+			query: "",
+			injector: func(m *MockItemRepository) {
+				m.EXPECT().Stream(gomock.Any(), "", "", gomock.Any()).DoAndReturn(stream(items))
+			},
+			wants: wants{
+				code:        http.StatusOK,
+				contentType: "application/json",
+				body: `{"items":[` +
+					`{"id":1,"name":"jacket","category":"fashion","image_name":"default.jpg","image_url":"http://example.com/v1/images/default.jpg",` +
+					`"links":{"self":"http://example.com/v1/items/1","category":"http://example.com/v1/items?category=fashion"}},` +
+					`{"id":2,"name":"mug, large","category":"kitchen","image_name":"mug.jpg","image_url":"http://example.com/v1/images/mug.jpg",` +
+					`"links":{"self":"http://example.com/v1/items/2","category":"http://example.com/v1/items?category=kitchen"}}]}` + "\n",
+			},
+		},
+		"ok: json without items": {
+			query: "?format=json",
+			injector: func(m *MockItemRepository) {
+				m.EXPECT().Stream(gomock.Any(), "", "", gomock.Any()).DoAndReturn(stream(nil))
+			},
+			wants: wants{code: http.StatusOK, contentType: "application/json", body: `{"items":[]}` + "\n"},
+		},
+		"ng: unknown format": {
+			query:    "?format=xml",
+			injector: func(m *MockItemRepository) {},
+			wants:    wants{code: http.StatusUnprocessableEntity, contentType: "application/json"},
+		},
+		"ng: the query fails before anything is written": {
+			query: "?format=csv",
+			injector: func(m *MockItemRepository) {
+				m.EXPECT().Stream(gomock.Any(), "", "", gomock.Any()).Return(errors.New("database is locked"))
+			},
+			wants: wants{code: http.StatusInternalServerError, contentType: "application/json"},
+		},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ctrl := gomock.NewController(t)
+			mockIR := NewMockItemRepository(ctrl)
+			tt.injector(mockIR)
+			h := &Handlers{itemRepo: mockIR}
+
+			req := httptest.NewRequest("GET", "/items/export"+tt.query, nil)
+			rr := httptest.NewRecorder()
+			h.ExportItems(rr, req)
+
+			if rr.Code != tt.wants.code {
+				t.Errorf("expected status code %d, got %d", tt.wants.code, rr.Code)
+			}
+			if got := rr.Header().Get("Content-Type"); got != tt.wants.contentType {
+				t.Errorf("expected content type %q, got %q", tt.wants.contentType, got)
+			}
+			if tt.wants.code >= 400 {
+				return
+			}
+			if got := rr.Body.String(); got != tt.wants.body {
+				t.Errorf("expected body %s, got %s", tt.wants.body, got)
+			}
+		})
+	}
+}
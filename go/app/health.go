@@ -0,0 +1,127 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// readinessCheckTimeout bounds each readiness check so that a stuck dependency doesn't stall the probe.
+const readinessCheckTimeout = 2 * time.Second
+
+type HealthResponse struct {
+	Status string `json:"status"`
+}
+
+// CheckResult is the result of a single readiness check.
+type CheckResult struct {
+	Status    string  `json:"status"` // "ok" or "fail"
+	LatencyMS float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+type ReadinessResponse struct {
+	Status string                 `json:"status"` // "ok" or "unavailable"
+	Checks map[string]CheckResult `json:"checks"`
+}
+
+// Healthz is a handler to tell that the process is alive for GET /healthz .
+// It doesn't check any dependency, so that a broken database doesn't get the process restarted.
+func (s *Handlers) Healthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	err := json.NewEncoder(w).Encode(HealthResponse{Status: "ok"})
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+}
+
+// Readyz is a handler to tell if the server can serve requests for GET /readyz .
+// It returns 503 if any dependency is unavailable or the server is shutting down.
+func (s *Handlers) Readyz(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	checks := map[string]func(ctx context.Context) error{
+		"database":      s.itemRepo.Ping,
+		"image_dir":     s.checkImageDirWritable,
+		"default_image": s.checkDefaultImage,
+		"shutdown":      s.checkNotShuttingDown,
+	}
+
+	resp := ReadinessResponse{Status: "ok", Checks: map[string]CheckResult{}}
+	for name, check := range checks {
+		result := runCheck(ctx, check)
+		if result.Status != "ok" {
+			slog.WarnContext(ctx, "readiness check failed", "check", name, "error", result.Error)
+			resp.Status = "unavailable"
+		}
+		resp.Checks[name] = result
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if resp.Status != "ok" {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	err := json.NewEncoder(w).Encode(resp)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+}
+
+// runCheck runs a readiness check with a timeout and measures its latency.
+func runCheck(ctx context.Context, check func(ctx context.Context) error) CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, readinessCheckTimeout)
+	defer cancel()
+
+	start := time.Now()
+	err := check(ctx)
+	result := CheckResult{
+		Status:    "ok",
+		LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		result.Status = "fail"
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// checkImageDirWritable checks that uploaded images can be stored.
+func (s *Handlers) checkImageDirWritable(ctx context.Context) error {
+	f, err := os.CreateTemp(s.imgDirPath, ".readyz-*")
+	if err != nil {
+		return fmt.Errorf("image directory is not writable: %w", err)
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
+
+// checkDefaultImage checks that the fallback of GetImage exists.
+func (s *Handlers) checkDefaultImage(ctx context.Context) error {
+	stat, err := os.Stat(filepath.Join(s.imgDirPath, "default.jpg"))
+	if err != nil {
+		return fmt.Errorf("default image is missing: %w", err)
+	}
+	if !stat.Mode().IsRegular() {
+		return errors.New("default image is not a regular file")
+	}
+	return nil
+}
+
+// checkNotShuttingDown fails once the server has started to shut down, so that load balancers
+// stop sending new requests during the drain delay, before the server stops accepting connections.
+func (s *Handlers) checkNotShuttingDown(ctx context.Context) error {
+	if s.shuttingDown.Load() {
+		return errors.New("server is shutting down")
+	}
+	return nil
+}
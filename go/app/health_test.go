@@ -0,0 +1,106 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.uber.org/mock/gomock"
+)
+
+func TestHealthz(t *testing.T) {
+	t.Parallel()
+
+	req := httptest.NewRequest("GET", "/healthz", nil)
+	rr := httptest.NewRecorder()
+	h := &Handlers{}
+	h.Healthz(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("expected status code %d, got %d", http.StatusOK, rr.Code)
+	}
+}
+
+func TestReadyz(t *testing.T) {
+	t.Parallel()
+
+	imageBytes, err := os.ReadFile("../images/default.jpg")
+	if err != nil {
+		t.Fatalf("failed to read image file: %v", err)
+	}
+
+	type wants struct {
+		code   int
+		failed []string
+	}
+	cases := map[string]struct {
+		pingErr      error
+		noDefault    bool
+		shuttingDown bool
+		wants
+	}{
+		"ok: ready": {
+			wants: wants{code: http.StatusOK},
+		},
+		"ng: database is down": {
+			pingErr: errors.New("database is locked"),
+			wants:   wants{code: http.StatusServiceUnavailable, failed: []string{"database"}},
+		},
+		"ng: default image is missing": {
+			noDefault: true,
+			wants:     wants{code: http.StatusServiceUnavailable, failed: []string{"default_image"}},
+		},
+		"ng: shutting down": {
+			shuttingDown: true,
+			wants:        wants{code: http.StatusServiceUnavailable, failed: []string{"shutdown"}},
+		},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			dir := t.TempDir()
+			if !tt.noDefault {
+				if err := os.WriteFile(filepath.Join(dir, "default.jpg"), imageBytes, 0644); err != nil {
+					t.Fatalf("failed to write image file: %v", err)
+				}
+			}
+
+			ctrl := gomock.NewController(t)
+			mockIR := NewMockItemRepository(ctrl)
+			mockIR.EXPECT().Ping(gomock.Any()).Return(tt.pingErr)
+			h := &Handlers{imgDirPath: dir, itemRepo: mockIR}
+			h.shuttingDown.Store(tt.shuttingDown)
+
+			req := httptest.NewRequest("GET", "/readyz", nil)
+			rr := httptest.NewRecorder()
+			h.Readyz(rr, req)
+
+			if rr.Code != tt.wants.code {
+				t.Errorf("expected status code %d, got %d", tt.wants.code, rr.Code)
+			}
+
+			var resp ReadinessResponse
+			if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("failed to unmarshal response body: %v", err)
+			}
+			if len(resp.Checks) != 4 {
+				t.Errorf("expected 4 checks, got %d", len(resp.Checks))
+			}
+			failed := map[string]bool{}
+			for _, name := range tt.wants.failed {
+				failed[name] = true
+			}
+			for name, result := range resp.Checks {
+				if got := result.Status != "ok"; got != failed[name] {
+					t.Errorf("unexpected status of check %s: %+v", name, result)
+				}
+			}
+		})
+	}
+}
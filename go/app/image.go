@@ -0,0 +1,334 @@
+package app
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"io"
+	"math/bits"
+	"os"
+	"sort"
+)
+
+// jpegQuality is the quality used when re-encoding uploaded images.
+const jpegQuality = 90
+
+// numDominantColors is the number of dominant colors stored for each image.
+const numDominantColors = 3
+
+// exifHeaderSize is how many bytes of an image are inspected for the EXIF segment.
+// An APP1 segment can't be longer than 64KB, so this is enough in practice.
+const exifHeaderSize = 128 << 10
+
+var (
+	errInvalidImage  = errors.New("invalid image")
+	errImageTooLarge = errors.New("image too large")
+)
+
+// uploadLimits limits the size of uploaded images.
+type uploadLimits struct {
+	// maxBytes is the maximum size of an image file in bytes.
+	maxBytes int64
+	// maxDimension is the maximum width and height of an image in pixels.
+	// This rejects decompression bombs, i.e. small files which decode into huge images.
+	maxDimension int
+}
+
+var defaultUploadLimits = uploadLimits{
+	maxBytes:     10 << 20, // 10MB
+	maxDimension: 8000,
+}
+
+// UploadedImage is an uploaded image which has been spooled to a temporary file.
+// Its hash and dimensions are those of the stored image, which is re-encoded, so they are computed by storeImage.
+type UploadedImage struct {
+	Path string // path to the temporary file
+	Size int64  // size in bytes
+}
+
+// Remove removes the temporary file. It is safe to call on a nil image.
+func (u *UploadedImage) Remove() error {
+	if u == nil {
+		return nil
+	}
+	return os.Remove(u.Path)
+}
+
+// receiveImage streams an image from src into a temporary file,
+// and checks it against the given limits without decoding the whole image.
+func receiveImage(src io.Reader, limits uploadLimits) (_ *UploadedImage, err error) {
+	tmp, err := os.CreateTemp("", "mercari-upload-*.jpg")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer func() {
+		tmp.Close()
+		if err != nil {
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	// read one byte more than the limit to tell if the image exceeds it
+	size, err := io.Copy(tmp, io.LimitReader(src, limits.maxBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image data: %w", err)
+	}
+	if size > limits.maxBytes {
+		return nil, fmt.Errorf("%w: image exceeds the maximum size of %d bytes", errImageTooLarge, limits.maxBytes)
+	}
+	if size == 0 {
+		return nil, fmt.Errorf("%w: image data is empty", errInvalidImage)
+	}
+
+	// only the header is decoded here
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("failed to read image data: %w", err)
+	}
+	cfg, format, err := image.DecodeConfig(tmp)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errInvalidImage, err)
+	}
+	if format != "jpeg" {
+		return nil, fmt.Errorf("%w: unsupported format %s", errInvalidImage, format)
+	}
+	if cfg.Width > limits.maxDimension || cfg.Height > limits.maxDimension {
+		return nil, fmt.Errorf("%w: image dimensions %dx%d exceed the maximum of %dx%d pixels",
+			errImageTooLarge, cfg.Width, cfg.Height, limits.maxDimension, limits.maxDimension)
+	}
+
+	return &UploadedImage{Path: tmp.Name(), Size: size}, nil
+}
+
+// sanitizeImage decodes a JPEG image from src and writes a re-encoded copy to dst.
+// The re-encoded image doesn't carry any metadata (EXIF, GPS, comments, etc.),
+// so the EXIF orientation is applied to the pixels beforehand to keep the photo upright.
+// It returns the decoded image after the orientation is applied.
+func sanitizeImage(dst io.Writer, src io.Reader) (image.Image, error) {
+	br := bufio.NewReaderSize(src, exifHeaderSize)
+
+	// look at the header without consuming it so that the decoder still sees the whole image
+	header, err := br.Peek(exifHeaderSize)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, bufio.ErrBufferFull) {
+		return nil, fmt.Errorf("failed to read image header: %w", err)
+	}
+	orientation := readOrientation(header)
+
+	img, err := jpeg.Decode(br)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errInvalidImage, err)
+	}
+
+	img = applyOrientation(img, orientation)
+	err = jpeg.Encode(dst, img, &jpeg.Options{Quality: jpegQuality})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode image: %w", err)
+	}
+	return img, nil
+}
+
+// readOrientation returns the EXIF orientation (1-8) of a JPEG image.
+// It returns 1 (normal) if the image has no EXIF data or the data is broken.
+func readOrientation(data []byte) int {
+	const orientationTag = 0x0112
+
+	// SOI marker
+	if len(data) < 2 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 1
+	}
+	pos := 2
+	for pos+4 <= len(data) {
+		if data[pos] != 0xFF {
+			return 1
+		}
+		marker := data[pos+1]
+		// stop at the start of the image data
+		if marker == 0xDA || marker == 0xD9 {
+			return 1
+		}
+		size := int(binary.BigEndian.Uint16(data[pos+2:]))
+		if size < 2 || pos+2+size > len(data) {
+			return 1
+		}
+		segment := data[pos+4 : pos+2+size]
+		pos += 2 + size
+
+		// APP1 segment starting with "Exif\0\0"
+		if marker != 0xE1 || !bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			continue
+		}
+		tiff := segment[6:]
+		if len(tiff) < 8 {
+			return 1
+		}
+		var order binary.ByteOrder
+		switch string(tiff[:2]) {
+		case "II":
+			order = binary.LittleEndian
+		case "MM":
+			order = binary.BigEndian
+		default:
+			return 1
+		}
+
+		// walk the entries of IFD0
+		ifd := int(order.Uint32(tiff[4:]))
+		if ifd+2 > len(tiff) {
+			return 1
+		}
+		count := int(order.Uint16(tiff[ifd:]))
+		for i := 0; i < count; i++ {
+			entry := ifd + 2 + i*12
+			if entry+12 > len(tiff) {
+				return 1
+			}
+			if order.Uint16(tiff[entry:]) != orientationTag {
+				continue
+			}
+			o := int(order.Uint16(tiff[entry+8:]))
+			if o < 1 || o > 8 {
+				return 1
+			}
+			return o
+		}
+		return 1
+	}
+	return 1
+}
+
+// applyOrientation rotates and flips img so that it is displayed upright
+// according to the given EXIF orientation.
+func applyOrientation(img image.Image, orientation int) image.Image {
+	if orientation <= 1 || orientation > 8 {
+		return img
+	}
+
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	dw, dh := w, h
+	if orientation >= 5 {
+		// orientations 5-8 swap width and height
+		dw, dh = h, w
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		for x := 0; x < dw; x++ {
+			var sx, sy int
+			switch orientation {
+			case 2: // flip horizontal
+				sx, sy = w-1-x, y
+			case 3: // rotate 180
+				sx, sy = w-1-x, h-1-y
+			case 4: // flip vertical
+				sx, sy = x, h-1-y
+			case 5: // transpose
+				sx, sy = y, x
+			case 6: // rotate 90 clockwise
+				sx, sy = y, h-1-x
+			case 7: // transverse
+				sx, sy = w-1-y, h-1-x
+			case 8: // rotate 90 counter-clockwise
+				sx, sy = w-1-y, x
+			}
+			dst.Set(x, y, img.At(b.Min.X+sx, b.Min.Y+sy))
+		}
+	}
+	return dst
+}
+
+// perceptualHash computes the difference hash (dHash) of img.
+// Unlike SHA-256, images which look alike, e.g. the same photo recompressed or slightly cropped,
+// get hashes with a small Hamming distance.
+func perceptualHash(img image.Image) uint64 {
+	const w, h = 9, 8
+	// the number of pixels sampled along each side of a cell, to bound the cost for large images
+	const samples = 16
+
+	// shrink the image to 9x8 grayscale cells
+	var cells [h][w]float64
+	bounds := img.Bounds()
+	for cy := 0; cy < h; cy++ {
+		y0, y1 := bounds.Min.Y+cy*bounds.Dy()/h, bounds.Min.Y+(cy+1)*bounds.Dy()/h
+		for cx := 0; cx < w; cx++ {
+			x0, x1 := bounds.Min.X+cx*bounds.Dx()/w, bounds.Min.X+(cx+1)*bounds.Dx()/w
+			ystep, xstep := max((y1-y0)/samples, 1), max((x1-x0)/samples, 1)
+
+			var sum float64
+			var n int
+			for y := y0; y < y1; y += ystep {
+				for x := x0; x < x1; x += xstep {
+					r, g, b, _ := img.At(x, y).RGBA()
+					sum += 0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)
+					n++
+				}
+			}
+			if n > 0 {
+				cells[cy][cx] = sum / float64(n)
+			}
+		}
+	}
+
+	// each bit tells whether the brightness increases from left to right
+	var hash uint64
+	for y := 0; y < h; y++ {
+		for x := 0; x < w-1; x++ {
+			if cells[y][x] < cells[y][x+1] {
+				hash |= 1 << (y*(w-1) + x)
+			}
+		}
+	}
+	return hash
+}
+
+// hammingDistance returns the number of different bits between two perceptual hashes.
+func hammingDistance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// dominantColors returns up to n colors which cover the largest areas of img, most dominant first.
+// The colors are formatted as "#rrggbb" so that clients can use them as placeholders.
+func dominantColors(img image.Image, n int) []string {
+	// the number of pixels sampled along each side, to bound the cost for large images
+	const samples = 64
+
+	type bucket struct {
+		r, g, b uint64
+		count   int
+	}
+	// quantize each channel to 3 bits so that close colors fall into the same bucket
+	var buckets [512]bucket
+
+	bounds := img.Bounds()
+	ystep, xstep := max(bounds.Dy()/samples, 1), max(bounds.Dx()/samples, 1)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y += ystep {
+		for x := bounds.Min.X; x < bounds.Max.X; x += xstep {
+			r, g, b, _ := img.At(x, y).RGBA()
+			r, g, b = r>>8, g>>8, b>>8
+			bk := &buckets[(r>>5)<<6|(g>>5)<<3|b>>5]
+			bk.r += uint64(r)
+			bk.g += uint64(g)
+			bk.b += uint64(b)
+			bk.count++
+		}
+	}
+
+	sorted := make([]bucket, 0, len(buckets))
+	for _, bk := range buckets {
+		if bk.count > 0 {
+			sorted = append(sorted, bk)
+		}
+	}
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].count > sorted[j].count })
+
+	colors := []string{}
+	for _, bk := range sorted[:min(n, len(sorted))] {
+		// the average color of the bucket is closer to the real color than the bucket itself
+		c := uint64(bk.count)
+		colors = append(colors, fmt.Sprintf("#%02x%02x%02x", bk.r/c, bk.g/c, bk.b/c))
+	}
+	return colors
+}
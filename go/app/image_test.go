@@ -0,0 +1,199 @@
+package app
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// buildJPEG encodes a w x h image whose top-left 8x8 block is red and the rest is white.
+func buildJPEG(t *testing.T, w, h int) []byte {
+	t.Helper()
+
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if x < 8 && y < 8 {
+				img.Set(x, y, color.RGBA{R: 255, A: 255})
+				continue
+			}
+			img.Set(x, y, color.White)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 100}); err != nil {
+		t.Fatalf("failed to encode image: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// withExif inserts an APP1 segment with the given orientation and a GPS IFD pointer right after SOI.
+func withExif(data []byte, orientation uint16) []byte {
+	var tiff bytes.Buffer
+	tiff.WriteString("II")
+	binary.Write(&tiff, binary.LittleEndian, uint16(42))
+	binary.Write(&tiff, binary.LittleEndian, uint32(8)) // offset of IFD0
+	binary.Write(&tiff, binary.LittleEndian, uint16(2)) // number of entries
+	// orientation (SHORT)
+	binary.Write(&tiff, binary.LittleEndian, []uint16{0x0112, 3})
+	binary.Write(&tiff, binary.LittleEndian, uint32(1))
+	binary.Write(&tiff, binary.LittleEndian, []uint16{orientation, 0})
+	// GPS IFD pointer (LONG)
+	binary.Write(&tiff, binary.LittleEndian, []uint16{0x8825, 4})
+	binary.Write(&tiff, binary.LittleEndian, uint32(1))
+	binary.Write(&tiff, binary.LittleEndian, uint32(0))
+	binary.Write(&tiff, binary.LittleEndian, uint32(0)) // no next IFD
+
+	payload := append([]byte("Exif\x00\x00"), tiff.Bytes()...)
+	segment := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(segment[2:], uint16(len(payload)+2))
+	segment = append(segment, payload...)
+
+	out := append([]byte{}, data[:2]...)
+	out = append(out, segment...)
+	return append(out, data[2:]...)
+}
+
+func TestReadOrientation(t *testing.T) {
+	t.Parallel()
+
+	plain := buildJPEG(t, 32, 16)
+
+	cases := map[string]struct {
+		data []byte
+		want int
+	}{
+		"ok: no exif":      {data: plain, want: 1},
+		"ok: rotated":      {data: withExif(plain, 6), want: 6},
+		"ok: flipped":      {data: withExif(plain, 2), want: 2},
+		"ng: out of range": {data: withExif(plain, 9), want: 1},
+		"ng: not a jpeg":   {data: []byte("not a jpeg"), want: 1},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := readOrientation(tt.data); got != tt.want {
+				t.Errorf("expected orientation %d, got %d", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestSanitizeImage(t *testing.T) {
+	t.Parallel()
+
+	src := withExif(buildJPEG(t, 32, 16), 6)
+
+	var dst bytes.Buffer
+	if _, err := sanitizeImage(&dst, bytes.NewReader(src)); err != nil {
+		t.Fatalf("failed to sanitize image: %v", err)
+	}
+
+	if bytes.Contains(dst.Bytes(), []byte("Exif")) {
+		t.Errorf("sanitized image still contains EXIF data")
+	}
+
+	img, err := jpeg.Decode(&dst)
+	if err != nil {
+		t.Fatalf("failed to decode sanitized image: %v", err)
+	}
+	// rotated 90 degrees clockwise, so the red block moves to the top-right corner
+	if got := img.Bounds().Size(); got != image.Pt(16, 32) {
+		t.Errorf("expected size 16x32, got %dx%d", got.X, got.Y)
+	}
+	if r, g, _, _ := img.At(12, 4).RGBA(); r < 0xc000 || g > 0x4000 {
+		t.Errorf("expected the top-right corner to be red, got %v", img.At(12, 4))
+	}
+	if _, g, _, _ := img.At(4, 4).RGBA(); g < 0xc000 {
+		t.Errorf("expected the top-left corner to be white, got %v", img.At(4, 4))
+	}
+
+	if _, err := sanitizeImage(&bytes.Buffer{}, bytes.NewReader([]byte("not a jpeg"))); err == nil {
+		t.Errorf("expected an error for invalid image data")
+	}
+}
+
+// buildPattern returns an image with a diagonal gradient and a few blocks, similar to a simple photo.
+func buildPattern(w, h int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			v := uint8((x*255/w + y*255/h) / 2)
+			if (x/(w/5)+y/(h/4))%3 == 0 {
+				v = 255 - v
+			}
+			img.Set(x, y, color.RGBA{R: v, G: v / 2, B: 255 - v, A: 255})
+		}
+	}
+	return img
+}
+
+func TestPerceptualHash(t *testing.T) {
+	t.Parallel()
+
+	original := buildPattern(400, 300)
+	hash := perceptualHash(original)
+
+	// recompress with a low quality
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, original, &jpeg.Options{Quality: 20}); err != nil {
+		t.Fatalf("failed to encode image: %v", err)
+	}
+	recompressed, err := jpeg.Decode(&buf)
+	if err != nil {
+		t.Fatalf("failed to decode image: %v", err)
+	}
+
+	// flip horizontally
+	flipped := applyOrientation(original, 2)
+
+	cases := map[string]struct {
+		img     image.Image
+		similar bool
+	}{
+		"ok: identical":    {img: original, similar: true},
+		"ok: recompressed": {img: recompressed, similar: true},
+		"ok: cropped":      {img: original.SubImage(image.Rect(8, 6, 392, 294)), similar: true},
+		"ng: flipped":      {img: flipped, similar: false},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			d := hammingDistance(hash, perceptualHash(tt.img))
+			if similar := d <= defaultSimilarImageThreshold; similar != tt.similar {
+				t.Errorf("expected similar=%v, got distance %d", tt.similar, d)
+			}
+		})
+	}
+}
+
+func TestDominantColors(t *testing.T) {
+	t.Parallel()
+
+	// 3/4 red and 1/4 blue
+	img := image.NewRGBA(image.Rect(0, 0, 40, 40))
+	for y := 0; y < 40; y++ {
+		for x := 0; x < 40; x++ {
+			c := color.RGBA{R: 250, G: 10, B: 10, A: 255}
+			if x >= 30 {
+				c = color.RGBA{R: 10, G: 10, B: 250, A: 255}
+			}
+			img.Set(x, y, c)
+		}
+	}
+
+	got := dominantColors(img, 3)
+	if diff := cmp.Diff([]string{"#fa0a0a", "#0a0afa"}, got); diff != "" {
+		t.Errorf("unexpected colors (-want +got):\n%s", diff)
+	}
+}
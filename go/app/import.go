@@ -0,0 +1,349 @@
+package app
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	// importBatchSize is the number of rows inserted in a transaction.
+	// A failing batch doesn't roll back the other batches, so a large import makes progress.
+	importBatchSize = 100
+	// maxImportRows is the maximum number of rows in an import.
+	maxImportRows = 10000
+	// maxImportLineBytes is the maximum size of a JSON Lines row.
+	maxImportLineBytes = 64 << 10
+)
+
+// Import formats.
+const (
+	importFormatCSV   = "csv"
+	importFormatJSONL = "jsonl"
+)
+
+// Statuses of an imported row.
+const (
+	importStatusImported = "imported"
+	importStatusValid    = "valid" // the row would be imported, in a dry run
+	importStatusInvalid  = "invalid"
+	importStatusFailed   = "failed" // the row is valid, but its batch failed to be inserted
+)
+
+// errInvalidImport is returned when an import can't be read at all, as opposed to having invalid rows.
+var errInvalidImport = errors.New("invalid import")
+
+// ImportRow is a row of an import.
+// CSV imports have a header naming the columns, and JSON Lines imports have an object per line.
+type ImportRow struct {
+	Name     string `json:"name"`
+	Category string `json:"category"`
+	// ImageName is the name of a stored image. The default image is used if it is empty.
+	ImageName string `json:"image_name"`
+}
+
+// ImportRowResult is the result of importing a row.
+type ImportRowResult struct {
+	Line   int          `json:"line"` // line number in the import, starting from 1
+	Status string       `json:"status"`
+	ID     int          `json:"id,omitempty"` // ID of the imported item
+	Errors []FieldError `json:"errors,omitempty"`
+}
+
+// ImportItemsResponse is the report of an import.
+type ImportItemsResponse struct {
+	DryRun   bool              `json:"dry_run"`
+	Total    int               `json:"total"`
+	Imported int               `json:"imported"`
+	Invalid  int               `json:"invalid"`
+	Failed   int               `json:"failed"`
+	Rows     []ImportRowResult `json:"rows"`
+}
+
+// ItemImporter imports items in bulk. It is used by POST /items/import and the import command.
+type ItemImporter struct {
+	Repo     ItemRepository
+	ImageDir string
+	// DryRun validates the rows without inserting them.
+	DryRun bool
+}
+
+// Import reads the rows in format, validates them with the same rules as AddItem
+// and inserts the valid ones in batches. It returns an error only if the import can't be read,
+// in which case nothing is inserted; invalid rows and failed batches are reported in the response.
+func (im *ItemImporter) Import(ctx context.Context, r io.Reader, format string) (*ImportItemsResponse, error) {
+	resp := &ImportItemsResponse{DryRun: im.DryRun, Rows: []ImportRowResult{}}
+	// items[i] is the item of resp.Rows[i], or nil if the row is invalid
+	var items []*Item
+
+	// read all rows before inserting any, so that an unreadable import doesn't leave some of its rows behind
+	err := readImportRows(r, format, func(line int, row ImportRow, rowErr error) error {
+		if len(resp.Rows) == maxImportRows {
+			return fmt.Errorf("%w: more than %d rows", errInvalidImport, maxImportRows)
+		}
+		result := ImportRowResult{Line: line, Status: importStatusValid}
+		var item *Item
+		if rowErr != nil {
+			result.Errors = []FieldError{{Message: rowErr.Error()}}
+		} else {
+			item, result.Errors = im.validateRow(row)
+		}
+		if len(result.Errors) > 0 {
+			result.Status = importStatusInvalid
+		}
+		resp.Rows = append(resp.Rows, result)
+		items = append(items, item)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if !im.DryRun {
+		var valid []int // indices of the valid rows
+		for i, item := range items {
+			if item != nil {
+				valid = append(valid, i)
+			}
+		}
+		for batch := range slices.Chunk(valid, importBatchSize) {
+			im.insertBatch(ctx, batch, items, resp.Rows)
+		}
+	}
+
+	resp.Total = len(resp.Rows)
+	for _, row := range resp.Rows {
+		switch row.Status {
+		case importStatusImported:
+			resp.Imported++
+		case importStatusInvalid:
+			resp.Invalid++
+		case importStatusFailed:
+			resp.Failed++
+		}
+	}
+	return resp, nil
+}
+
+// insertBatch inserts the items of the rows with the given indices in a transaction and updates their results.
+func (im *ItemImporter) insertBatch(ctx context.Context, indices []int, items []*Item, results []ImportRowResult) {
+	batch := make([]*Item, len(indices))
+	for i, index := range indices {
+		batch[i] = items[index]
+	}
+	err := im.Repo.InsertBatch(ctx, batch)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to import items: ", "error", err)
+	}
+	for _, index := range indices {
+		if err != nil {
+			results[index].Status = importStatusFailed
+			results[index].Errors = []FieldError{{Message: "failed to insert the item"}}
+			continue
+		}
+		results[index].Status = importStatusImported
+		results[index].ID = items[index].ID
+	}
+}
+
+// validateRow validates a row as AddItem would validate a request, and returns the item to insert.
+func (im *ItemImporter) validateRow(row ImportRow) (*Item, []FieldError) {
+	req := &AddItemRequest{Name: row.Name, Category: row.Category}
+	violations := validateStruct(req)
+
+	imageName := strings.TrimSpace(row.ImageName)
+	if imageName == "" {
+		imageName = "default.jpg"
+	} else if _, err := buildImagePath(im.ImageDir, imageName); err != nil {
+		violations = append(violations, FieldError{Field: "image_name", Message: "must be the name of a stored image"})
+	}
+	if len(violations) > 0 {
+		return nil, violations
+	}
+	return &Item{Name: req.Name, Category: req.Category, ImageName: imageName}, nil
+}
+
+// readImportRows calls fn with each row of r and its line number.
+// A row which can't be parsed is passed with rowErr, so that the other rows can still be imported.
+func readImportRows(r io.Reader, format string, fn func(line int, row ImportRow, rowErr error) error) error {
+	switch format {
+	case importFormatCSV:
+		return readCSVRows(r, fn)
+	case importFormatJSONL:
+		return readJSONLRows(r, fn)
+	default:
+		return fmt.Errorf("%w: unsupported format %q, must be csv or jsonl", errInvalidImport, format)
+	}
+}
+
+func readCSVRows(r io.Reader, fn func(line int, row ImportRow, rowErr error) error) error {
+	cr := csv.NewReader(r)
+	cr.TrimLeadingSpace = true
+	header, err := cr.Read()
+	if errors.Is(err, io.EOF) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("%w: failed to read the header: %w", errInvalidImport, err)
+	}
+
+	// the columns are found by name, so that spreadsheets can have them in any order or have extra ones.
+	// Spreadsheets may also start the file with a byte order mark.
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+	for _, required := range []string{"name", "category"} {
+		if _, ok := columns[required]; !ok {
+			return fmt.Errorf("%w: the header has no %s column", errInvalidImport, required)
+		}
+	}
+	// the cells of an export are escaped so that spreadsheets don't evaluate them
+	column := func(record []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return unescapeCSVFormula(record[i])
+		}
+		return ""
+	}
+
+	for {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		var rowErr error
+		if err != nil {
+			// a row with a wrong number of fields is still returned, anything else can't be read further
+			if !errors.Is(err, csv.ErrFieldCount) {
+				var parseErr *csv.ParseError
+				if errors.As(err, &parseErr) {
+					return fmt.Errorf("%w: line %d: %w", errInvalidImport, parseErr.StartLine, parseErr.Err)
+				}
+				return fmt.Errorf("%w: %w", errInvalidImport, err)
+			}
+			rowErr = fmt.Errorf("has %d fields, but the header has %d", len(record), len(header))
+		}
+		// the position is only known for a record which was read
+		line, _ := cr.FieldPos(0)
+		row := ImportRow{
+			Name:      column(record, "name"),
+			Category:  column(record, "category"),
+			ImageName: column(record, "image_name"),
+		}
+		if err := fn(line, row, rowErr); err != nil {
+			return err
+		}
+	}
+}
+
+func readJSONLRows(r io.Reader, fn func(line int, row ImportRow, rowErr error) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, maxImportLineBytes)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		var row ImportRow
+		var rowErr error
+		if err := json.Unmarshal([]byte(text), &row); err != nil {
+			rowErr = errors.New("is not a JSON object of an item")
+		}
+		if err := fn(line, row, rowErr); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("%w: %w", errInvalidImport, err)
+	}
+	return nil
+}
+
+// ImportFormat returns the import format of a media type or a file extension such as ".csv", or "".
+func ImportFormat(typeOrExt string) string {
+	mediaType, _, _ := mime.ParseMediaType(typeOrExt)
+	switch {
+	case mediaType == "text/csv", strings.EqualFold(typeOrExt, ".csv"):
+		return importFormatCSV
+	case slices.Contains([]string{"application/jsonl", "application/x-ndjson", "application/x-jsonlines"}, mediaType),
+		strings.EqualFold(typeOrExt, ".jsonl"), strings.EqualFold(typeOrExt, ".ndjson"):
+		return importFormatJSONL
+	}
+	return ""
+}
+
+type ImportItemsRequest struct {
+	Format string `query:"format"` // csv or jsonl; taken from Content-Type if empty
+	DryRun bool   `query:"dry_run"`
+}
+
+// parseImportItemsRequest parses and validates the request to import items.
+func parseImportItemsRequest(r *http.Request) (*ImportItemsRequest, error) {
+	req := &ImportItemsRequest{
+		Format: strings.TrimSpace(r.URL.Query().Get("format")),
+	}
+
+	var violations []FieldError
+	if req.Format == "" {
+		req.Format = ImportFormat(r.Header.Get("Content-Type"))
+	}
+	if req.Format != importFormatCSV && req.Format != importFormatJSONL {
+		violations = append(violations, FieldError{Field: "format", Message: "must be csv or jsonl, or given by Content-Type"})
+	}
+	if v := r.URL.Query().Get("dry_run"); v != "" {
+		dryRun, err := strconv.ParseBool(v)
+		if err != nil {
+			violations = append(violations, FieldError{Field: "dry_run", Message: "must be true or false"})
+		}
+		req.DryRun = dryRun
+	}
+	if len(violations) > 0 {
+		return nil, validationError(violations)
+	}
+
+	return req, nil
+}
+
+// ImportItems is a handler to import items from CSV or JSON Lines for POST /items/import .
+// The response reports the result of each row, so it is 200 even if some rows are invalid.
+func (s *Handlers) ImportItems(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	req, err := parseImportItemsRequest(r)
+	if err != nil {
+		slog.WarnContext(ctx, "failed to parse import items request: ", "error", err)
+		writeError(w, r, badRequest(err))
+		return
+	}
+
+	im := &ItemImporter{Repo: s.itemRepo, ImageDir: s.imgDirPath, DryRun: req.DryRun}
+	resp, err := im.Import(ctx, r.Body, req.Format)
+	if err != nil {
+		if errors.Is(err, errInvalidImport) {
+			slog.WarnContext(ctx, "failed to read import: ", "error", err)
+			writeError(w, r, badRequest(err))
+			return
+		}
+		// e.g. the body exceeds the limit
+		writeError(w, r, err)
+		return
+	}
+	slog.InfoContext(ctx, "imported items", "dry_run", resp.DryRun, "total", resp.Total,
+		"imported", resp.Imported, "invalid", resp.Invalid, "failed", resp.Failed)
+
+	err = json.NewEncoder(w).Encode(resp)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+}
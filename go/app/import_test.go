@@ -0,0 +1,323 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"go.uber.org/mock/gomock"
+)
+
+func TestItemImporter(t *testing.T) {
+	t.Parallel()
+
+	// insertBatch mocks InsertBatch by numbering the items from id
+	insertBatch := func(id int) func(context.Context, []*Item) error {
+		return func(_ context.Context, items []*Item) error {
+			for _, item := range items {
+				item.ID = id
+				id++
+			}
+			return nil
+		}
+	}
+
+	type wants struct {
+		resp *ImportItemsResponse
+		err  bool
+	}
+
+	cases := map[string]struct {
+		format   string
+		body     string
+		dryRun   bool
+		injector func(m *MockItemRepository)
+		wants
+	}{
+		"ok: csv with columns in any order": {
+			format: importFormatCSV,
+			body:   "\ufeffcategory,name,image_name\nfashion,jacket,\n kitchen , mug ,default.jpg\n",
+			injector: func(m *MockItemRepository) {
+				m.EXPECT().InsertBatch(gomock.Any(), []*Item{
+					{Name: "jacket", Category: "fashion", ImageName: "default.jpg"},
+					{Name: "mug", Category: "kitchen", ImageName: "default.jpg"},
+				}).DoAndReturn(insertBatch(1))
+			},
+			wants: wants{
+				resp: &ImportItemsResponse{
+					Total: 2, Imported: 2,
+					Rows: []ImportRowResult{
+						{Line: 2, Status: importStatusImported, ID: 1},
+						{Line: 3, Status: importStatusImported, ID: 2},
+					},
+				},
+			},
+		},
+		"ok: csv cells escaped by an export": {
+			format: importFormatCSV,
+			body:   "name,category\n'=1+1,'@fashion\n'jacket,fashion\n",
+			injector: func(m *MockItemRepository) {
+				m.EXPECT().InsertBatch(gomock.Any(), []*Item{
+					{Name: "=1+1", Category: "@fashion", ImageName: "default.jpg"},
+					{Name: "'jacket", Category: "fashion", ImageName: "default.jpg"},
+				}).DoAndReturn(insertBatch(1))
+			},
+			wants: wants{
+				resp: &ImportItemsResponse{
+					Total: 2, Imported: 2,
+					Rows: []ImportRowResult{
+						{Line: 2, Status: importStatusImported, ID: 1},
+						{Line: 3, Status: importStatusImported, ID: 2},
+					},
+				},
+			},
+		},
+		"ok: jsonl with invalid rows": {
+			format: importFormatJSONL,
+			body: `{"name": "jacket", "category": "fashion"}
+{"name": "  ", "category": "fashion"}
+
+{"name": "mug", "category": "kitchen", "image_name": "missing.jpg"}
+not json
+`,
+			injector: func(m *MockItemRepository) {
+				m.EXPECT().InsertBatch(gomock.Any(), gomock.Len(1)).DoAndReturn(insertBatch(1))
+			},
+			wants: wants{
+				resp: &ImportItemsResponse{
+					Total: 4, Imported: 1, Invalid: 3,
+					Rows: []ImportRowResult{
+						{Line: 1, Status: importStatusImported, ID: 1},
+						{Line: 2, Status: importStatusInvalid},
+						{Line: 4, Status: importStatusInvalid},
+						{Line: 5, Status: importStatusInvalid},
+					},
+				},
+			},
+		},
+		"ok: csv row with a wrong number of fields": {
+			format: importFormatCSV,
+			body:   "name,category\njacket\nmug,kitchen\n",
+			injector: func(m *MockItemRepository) {
+				m.EXPECT().InsertBatch(gomock.Any(), gomock.Len(1)).DoAndReturn(insertBatch(1))
+			},
+			wants: wants{
+				resp: &ImportItemsResponse{
+					Total: 2, Imported: 1, Invalid: 1,
+					Rows: []ImportRowResult{
+						{Line: 2, Status: importStatusInvalid},
+						{Line: 3, Status: importStatusImported, ID: 1},
+					},
+				},
+			},
+		},
+		"ok: dry run doesn't insert": {
+			format:   importFormatCSV,
+			body:     "name,category\njacket,fashion\n,fashion\n",
+			dryRun:   true,
+			injector: func(m *MockItemRepository) {},
+			wants: wants{
+				resp: &ImportItemsResponse{
+					DryRun: true, Total: 2, Invalid: 1,
+					Rows: []ImportRowResult{
+						{Line: 2, Status: importStatusValid},
+						{Line: 3, Status: importStatusInvalid},
+					},
+				},
+			},
+		},
+		"ok: rows of a failed batch are reported": {
+			format: importFormatJSONL,
+			body:   `{"name": "jacket", "category": "fashion"}`,
+			injector: func(m *MockItemRepository) {
+				m.EXPECT().InsertBatch(gomock.Any(), gomock.Any()).Return(errors.New("database is locked"))
+			},
+			wants: wants{
+				resp: &ImportItemsResponse{
+					Total: 1, Failed: 1,
+					Rows: []ImportRowResult{
+						{Line: 1, Status: importStatusFailed},
+					},
+				},
+			},
+		},
+		"ok: empty import": {
+			format:   importFormatCSV,
+			body:     "",
+			injector: func(m *MockItemRepository) {},
+			wants: wants{
+				resp: &ImportItemsResponse{Rows: []ImportRowResult{}},
+			},
+		},
+		"ng: header without a name column": {
+			format:   importFormatCSV,
+			body:     "title,category\njacket,fashion\n",
+			injector: func(m *MockItemRepository) {},
+			wants:    wants{err: true},
+		},
+		"ng: malformed csv": {
+			format:   importFormatCSV,
+			body:     "name,category\njacket,fashion\n\"mug,kitchen\n",
+			injector: func(m *MockItemRepository) {},
+			wants:    wants{err: true},
+		},
+		"ng: bare quote in csv": {
+			format:   importFormatCSV,
+			body:     "name,category\n12\" vinyl,music\n",
+			injector: func(m *MockItemRepository) {},
+			wants:    wants{err: true},
+		},
+		"ng: unsupported format": {
+			format:   "xml",
+			body:     "<items/>",
+			injector: func(m *MockItemRepository) {},
+			wants:    wants{err: true},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ctrl := gomock.NewController(t)
+			mockRepo := NewMockItemRepository(ctrl)
+			tc.injector(mockRepo)
+
+			im := &ItemImporter{Repo: mockRepo, ImageDir: "../images", DryRun: tc.dryRun}
+			got, err := im.Import(context.Background(), strings.NewReader(tc.body), tc.format)
+			if tc.wants.err {
+				if !errors.Is(err, errInvalidImport) {
+					t.Fatalf("expected an invalid import error, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			// the messages are tested with the validation rules, here only the statuses are compared
+			if diff := cmp.Diff(tc.wants.resp, got, cmpopts.IgnoreFields(ImportRowResult{}, "Errors")); diff != "" {
+				t.Errorf("unexpected response (-want +got):\n%s", diff)
+			}
+			for _, row := range got.Rows {
+				if (row.Status == importStatusInvalid || row.Status == importStatusFailed) != (len(row.Errors) > 0) {
+					t.Errorf("line %d: unexpected errors for status %s: %v", row.Line, row.Status, row.Errors)
+				}
+			}
+		})
+	}
+}
+
+func TestImportItems(t *testing.T) {
+	t.Parallel()
+
+	type wants struct {
+		code   int
+		dryRun bool
+	}
+	cases := map[string]struct {
+		query       string
+		contentType string
+		body        string
+		injector    func(m *MockItemRepository)
+		wants
+	}{
+		"ok: format from the query": {
+			query:       "?format=csv",
+			contentType: "text/plain",
+			body:        "name,category\njacket,fashion\n",
+			injector: func(m *MockItemRepository) {
+				m.EXPECT().InsertBatch(gomock.Any(), gomock.Len(1)).Return(nil)
+			},
+			wants: wants{code: http.StatusOK},
+		},
+		"ok: format from Content-Type": {
+			contentType: "application/x-ndjson",
+			body:        `{"name": "jacket", "category": "fashion"}`,
+			injector: func(m *MockItemRepository) {
+				m.EXPECT().InsertBatch(gomock.Any(), gomock.Len(1)).Return(nil)
+			},
+			wants: wants{code: http.StatusOK},
+		},
+		"ok: dry run": {
+			query:    "?format=csv&dry_run=true",
+			body:     "name,category\njacket,fashion\n",
+			injector: func(m *MockItemRepository) {},
+			wants:    wants{code: http.StatusOK, dryRun: true},
+		},
+		"ng: no format": {
+			contentType: "application/json",
+			body:        `{"name": "jacket", "category": "fashion"}`,
+			injector:    func(m *MockItemRepository) {},
+			wants:       wants{code: http.StatusUnprocessableEntity},
+		},
+		"ng: invalid dry_run": {
+			query:    "?format=csv&dry_run=maybe",
+			body:     "name,category\njacket,fashion\n",
+			injector: func(m *MockItemRepository) {},
+			wants:    wants{code: http.StatusUnprocessableEntity},
+		},
+		"ng: malformed csv": {
+			query:    "?format=csv",
+			body:     "name,category\n12\" vinyl,music\n",
+			injector: func(m *MockItemRepository) {},
+			wants:    wants{code: http.StatusBadRequest},
+		},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ctrl := gomock.NewController(t)
+			mockIR := NewMockItemRepository(ctrl)
+			tt.injector(mockIR)
+			h := &Handlers{itemRepo: mockIR, imgDirPath: "../images"}
+
+			req := httptest.NewRequest("POST", "/items/import"+tt.query, strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			rr := httptest.NewRecorder()
+			h.ImportItems(rr, req)
+
+			if rr.Code != tt.wants.code {
+				t.Fatalf("expected status code %d, got %d: %s", tt.wants.code, rr.Code, rr.Body)
+			}
+			if tt.wants.code >= 400 {
+				return
+			}
+			var resp ImportItemsResponse
+			if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if resp.DryRun != tt.wants.dryRun || resp.Total != 1 {
+				t.Errorf("unexpected response: %+v", resp)
+			}
+		})
+	}
+}
+
+func TestImportFormat(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]string{
+		"text/csv; charset=utf-8": importFormatCSV,
+		"application/x-ndjson":    importFormatJSONL,
+		".CSV":                    importFormatCSV,
+		".jsonl":                  importFormatJSONL,
+		"application/json":        "",
+		"":                        "",
+	}
+	for typeOrExt, want := range cases {
+		if got := ImportFormat(typeOrExt); got != want {
+			t.Errorf("ImportFormat(%q) = %q, want %q", typeOrExt, got, want)
+		}
+	}
+}
@@ -6,9 +6,10 @@ import (
 	"errors"
 	"fmt"
 	//"io"
-	//"os"
-	"path/filepath"
+	"os"
 	//"strconv"
+	"strings"
+	"time"
 	// STEP 5-1: uncomment this line
 	_ "github.com/mattn/go-sqlite3"
 	"database/sql"
@@ -16,9 +17,11 @@ import (
 
 //custom error
 var (
-    errImageNotFound = errors.New("image not found")
-    errItemNotFound  = errors.New("item not found")
-    errInvalidInput  = errors.New("invalid input")
+    errImageNotFound    = errors.New("image not found")
+    errItemNotFound     = errors.New("item not found")
+    errInvalidInput     = errors.New("invalid input")
+    errCategoryNotFound = errors.New("category not found")
+    errCategoryInUse    = errors.New("category has items")
 )
 
 type Item struct {
@@ -28,6 +31,25 @@ type Item struct {
 	ImageName  string `db:"image_name" json:"image_name"`
 }
 
+// ImageInfo describes an image stored in the image directory.
+// It is computed once when the image is uploaded.
+type ImageInfo struct {
+	Name           string   `db:"name" json:"name"`
+	Format         string   `db:"format" json:"format"`
+	Width          int      `db:"width" json:"width"`
+	Height         int      `db:"height" json:"height"`
+	Size           int64    `db:"size" json:"size"` // file size in bytes
+	SHA256         string   `db:"sha256" json:"sha256"`
+	DominantColors []string `db:"dominant_colors" json:"dominant_colors"` // e.g. "#aabbcc", most dominant first
+	PHash          uint64   `db:"phash" json:"-"`                         // perceptual hash of the image
+}
+
+// ItemImageHash is an item with the perceptual hash of its image.
+type ItemImageHash struct {
+	Item
+	PHash uint64
+}
+
 // Please run `go generate ./...` to generate the mock implementation
 // ItemRepository is an interface to manage items.
 //
@@ -37,9 +59,56 @@ type ItemRepository interface {
 	List(ctx context.Context) ([]Item, error) //get all items
 	Get(ctx context.Context, id string) (*Item, error) //get an item by id
 	Search(ctx context.Context, keyword string) ([]Item, error) //search items by keyword
+	ListByCategory(ctx context.Context, category string) ([]Item, error) //get items in a category by its name
+	InsertBatch(ctx context.Context, items []*Item) error //insert items in a single transaction
+	Stream(ctx context.Context, keyword, category string, fn func(Item) error) error //call fn with each item matching keyword and category, without loading them all
 	Close() error //close the database connection
+	Ping(ctx context.Context) error //check if the database is reachable
 	GetCategoryID(ctx context.Context, categoryName string) (int, error) //get category id by name
 	GetCategoryName(ctx context.Context, categoryID int) (string, error) //get category name by id
+	SaveImage(ctx context.Context, info *ImageInfo) error //save the information of a stored image
+	GetImageInfo(ctx context.Context, name string) (*ImageInfo, error) //get the information of a stored image by name
+	ListImageHashes(ctx context.Context) ([]ItemImageHash, error) //get all items with the perceptual hash of their image
+}
+
+// LegacyRepository is an ItemRepository with the operations of the legacy import command,
+// which the server doesn't use.
+type LegacyRepository interface {
+	ItemRepository
+	GetLegacyItemID(ctx context.Context, key string) (int, error) //get the id of the item imported under a legacy key
+	InsertLegacyItem(ctx context.Context, key string, item *Item) (bool, error) //insert an item of the legacy items.json unless its key was already imported
+}
+
+// AdminRepository is an ItemRepository with the operations of the admin command,
+// which the server doesn't use.
+type AdminRepository interface {
+	ItemRepository
+	Delete(ctx context.Context, id int) error //delete an item by id
+	ListCategories(ctx context.Context) ([]Category, error) //get all categories with their number of items
+	RenameCategory(ctx context.Context, name, newName string) error //rename a category
+	DeleteCategory(ctx context.Context, name string) error //delete a category without items
+	Migrate(ctx context.Context, schemaPath string) error //create the tables and apply the pending migrations
+	SchemaVersion(ctx context.Context) (int, error) //get the number of applied migrations
+	Vacuum(ctx context.Context) error //rebuild the database file to reclaim unused space
+	Backup(ctx context.Context, path string) error //write a consistent copy of the database to a new file
+	CatalogStats(ctx context.Context) (*CatalogStats, error) //get the statistics of the catalog
+}
+
+// Category is a category with the number of its items.
+type Category struct {
+	ID    int    `json:"id"`
+	Name  string `json:"name"`
+	Items int    `json:"items"`
+}
+
+// CatalogStats are the statistics of the catalog and its database.
+type CatalogStats struct {
+	Items         int   `json:"items"`
+	Categories    int   `json:"categories"`
+	Images        int   `json:"images"`         // images with metadata
+	DatabaseBytes int64 `json:"database_bytes"` // size of the database, including unused pages
+	FreeBytes     int64 `json:"free_bytes"`     // size of the unused pages reclaimed by Vacuum
+	SchemaVersion int   `json:"schema_version"`
 }
 
 // itemRepository is an implementation of ItemRepository
@@ -51,10 +120,49 @@ type itemRepository struct {
 	db *sql.DB
 }
 
+// NewLegacyRepository creates a new itemRepository for the legacy import command.
+// The database is opened and migrated as NewItemRepository does.
+func NewLegacyRepository(dsn, schemaPath string) (LegacyRepository, error) {
+	repo, err := NewItemRepository(dsn, schemaPath)
+	if err != nil {
+		return nil, err
+	}
+	return repo.(*itemRepository), nil
+}
+
+// OpenAdminRepository opens the database of dsn for the admin command.
+// Unlike NewItemRepository, it neither creates the tables nor applies migrations, so that
+// the admin command changes the schema only when it is asked to by Migrate.
+// The database must exist: a wrong path is an error instead of creating an empty database.
+func OpenAdminRepository(dsn string) (AdminRepository, error) {
+	_, params, _ := strings.Cut(dsn, "?")
+	db, err := openDB(withDSNParams("file:"+databasePath(dsn)+"?mode=rw", params))
+	if err != nil {
+		return nil, err
+	}
+	return &itemRepository{db: db}, nil
+}
+
 // NewItemRepository creates a new itemRepository.
-func NewItemRepository() (ItemRepository, error) {
-	dbPath := filepath.Join("db", "mercari.sqlite3")
-    db, err := sql.Open("sqlite3", dbPath)
+// dsn is the SQLite data source name and schemaPath is the SQL file creating the tables.
+// The tables are created and migrated to the latest schema.
+func NewItemRepository(dsn, schemaPath string) (ItemRepository, error) {
+	db, err := openDB(dsn)
+	if err != nil {
+		return nil, err
+	}
+	repo := &itemRepository{db: db}
+	if err := repo.Migrate(context.Background(), schemaPath); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return repo, nil
+}
+
+// openDB opens and pings the SQLite database of dsn.
+func openDB(dsn string) (*sql.DB, error) {
+    // foreign keys are enforced so that deleting an item cascades to the rows referring to it
+    db, err := sql.Open("sqlite3", withDSNParams(dsn, "_foreign_keys=1"))
     if err != nil {
         return nil, fmt.Errorf("failed to open database: %w", err)
     }
@@ -66,27 +174,105 @@ func NewItemRepository() (ItemRepository, error) {
 		db.Close()
         return nil, fmt.Errorf("failed to ping database: %w", err)
     }
+	return db, nil
+}
 
-    sqlBytes, err := os.ReadFile("db/items.sql")
-	if err != nil {
-		db.Close()
-		return nil, fmt.Errorf("failed to read items.sql: %w", err)
+// withDSNParams returns dsn with the query parameters params, e.g. "_foreign_keys=1", appended.
+// Parameters already in dsn take precedence.
+func withDSNParams(dsn string, params ...string) string {
+	for _, p := range params {
+		if p == "" {
+			continue
+		}
+		sep := "?"
+		if strings.Contains(dsn, "?") {
+			sep = "&"
+		}
+		dsn += sep + p
 	}
-	_, err = db.Exec(string(sqlBytes))
+	return dsn
+}
+
+// Migrate creates the tables with the SQL file at schemaPath and applies the pending migrations.
+func (i *itemRepository) Migrate(ctx context.Context, schemaPath string) error {
+    sqlBytes, err := os.ReadFile(schemaPath)
 	if err != nil {
-		db.Close()
-		return nil, fmt.Errorf("failed to execute items.sql: %w", err)
+		return fmt.Errorf("failed to read %s: %w", schemaPath, err)
+	}
+	if _, err := i.db.ExecContext(ctx, string(sqlBytes)); err != nil {
+		return fmt.Errorf("failed to execute %s: %w", schemaPath, err)
 	}
+	if err := migrate(ctx, i.db); err != nil {
+		return fmt.Errorf("failed to migrate database: %w", err)
+	}
+	return nil
+}
 
-	return &itemRepository{
-		db: db,
-	}, nil
+// migrations change the schema created by items.sql for existing databases.
+// The number of applied migrations is stored in PRAGMA user_version, so never reorder or edit them;
+// append a new one instead.
+var migrations = []string{
+	// 1: image metadata
+	`ALTER TABLE images ADD COLUMN format TEXT NOT NULL DEFAULT '';
+	ALTER TABLE images ADD COLUMN width INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE images ADD COLUMN height INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE images ADD COLUMN size INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE images ADD COLUMN sha256 TEXT NOT NULL DEFAULT '';
+	ALTER TABLE images ADD COLUMN dominant_colors TEXT NOT NULL DEFAULT '';`,
+	// 2: items imported from the legacy items.json, so that the import can be rerun
+	`CREATE TABLE IF NOT EXISTS legacy_items (
+		key TEXT PRIMARY KEY,
+		item_id INTEGER NOT NULL,
+		FOREIGN KEY (item_id) REFERENCES items(id) ON DELETE CASCADE
+	);`,
+}
+
+// migrate applies the migrations which haven't been applied to db yet.
+func migrate(ctx context.Context, db *sql.DB) error {
+	var version int
+	if err := db.QueryRowContext(ctx, "PRAGMA user_version").Scan(&version); err != nil {
+		return fmt.Errorf("failed to get schema version: %w", err)
+	}
+
+	for v := version; v < len(migrations); v++ {
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return fmt.Errorf("failed to begin transaction: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx, migrations[v]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to apply migration %d: %w", v+1, err)
+		}
+		// PRAGMA doesn't accept placeholders
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf("PRAGMA user_version = %d", v+1)); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to set schema version: %w", err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("failed to commit migration %d: %w", v+1, err)
+		}
+	}
+	return nil
 }
 
 func (i *itemRepository) Close() error {
     return i.db.Close()
 }
 
+// Ping checks if the database is reachable and can run a query.
+func (i *itemRepository) Ping(ctx context.Context) error {
+	var n int
+	if err := i.db.QueryRowContext(ctx, "SELECT 1").Scan(&n); err != nil {
+		return fmt.Errorf("failed to ping database: %w", err)
+	}
+	return nil
+}
+
+// Stats returns the statistics of the database connection pool.
+func (i *itemRepository) Stats() sql.DBStats {
+	return i.db.Stats()
+}
+
 // common query function
 func (i *itemRepository) queryItems(ctx context.Context, query string, args ...interface{}) ([]Item, error) {
     rows, err := i.db.QueryContext(ctx, query, args...)
@@ -155,6 +341,208 @@ func (i *itemRepository) List(ctx context.Context) ([]Item, error) {
     `)
 }
 
+// InsertBatch inserts items in a single transaction, creating missing categories, and sets their IDs.
+// Either all of the items are inserted or none of them.
+func (i *itemRepository) InsertBatch(ctx context.Context, items []*Item) (err error) {
+	tx, err := i.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	categoryIDs := map[string]int{}
+	for _, item := range items {
+		categoryID, ok := categoryIDs[item.Category]
+		if !ok {
+			categoryID, err = getOrCreateCategoryID(ctx, tx, item.Category)
+			if err != nil {
+				return err
+			}
+			categoryIDs[item.Category] = categoryID
+		}
+
+		result, err := tx.ExecContext(ctx, `
+			INSERT INTO items (name, category_id, image_name)
+			VALUES (?, ?, ?)
+		`, item.Name, categoryID, item.ImageName)
+		if err != nil {
+			return fmt.Errorf("failed to insert item: %w", err)
+		}
+		id, err := result.LastInsertId()
+		if err != nil {
+			return fmt.Errorf("failed to get last insert id: %w", err)
+		}
+		item.ID = int(id)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// ListByCategory returns the items in the category with the given name.
+func (i *itemRepository) ListByCategory(ctx context.Context, category string) ([]Item, error) {
+	return i.queryItems(ctx, `
+		SELECT i.id, i.name, c.name AS category, i.image_name
+		FROM items i
+		JOIN categories c ON i.category_id = c.id
+		WHERE c.name = ?
+	`, category)
+}
+
+// legacyItemIDQuery gets the ID of the item imported under a key.
+// A row left behind by an item deleted before foreign keys were enforced doesn't count,
+// so that the item is imported again instead of referring to an item which doesn't exist.
+const legacyItemIDQuery = `
+	SELECT l.item_id FROM legacy_items l
+	JOIN items i ON i.id = l.item_id
+	WHERE l.key = ?
+`
+
+// GetLegacyItemID returns the ID of the item imported under key, or errItemNotFound if it hasn't been imported.
+func (i *itemRepository) GetLegacyItemID(ctx context.Context, key string) (int, error) {
+	var itemID int
+	err := i.db.QueryRowContext(ctx, legacyItemIDQuery, key).Scan(&itemID)
+	if err == sql.ErrNoRows {
+		return 0, errItemNotFound
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to get legacy item: %w", err)
+	}
+	return itemID, nil
+}
+
+// InsertLegacyItem inserts an item imported from the legacy items.json, recording it under key.
+// If an item was already imported under key, nothing is inserted, item.ID is set to the existing item and false is returned.
+// The item and its key are inserted in a transaction, so that an interrupted import can be rerun safely.
+func (i *itemRepository) InsertLegacyItem(ctx context.Context, key string, item *Item) (_ bool, err error) {
+	if item == nil || key == "" {
+		return false, errInvalidInput
+	}
+
+	tx, err := i.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var itemID int
+	err = tx.QueryRowContext(ctx, legacyItemIDQuery, key).Scan(&itemID)
+	if err == nil {
+		item.ID = itemID
+		return false, tx.Commit()
+	}
+	if err != sql.ErrNoRows {
+		return false, fmt.Errorf("failed to get legacy item: %w", err)
+	}
+
+	categoryID, err := getOrCreateCategoryID(ctx, tx, item.Category)
+	if err != nil {
+		return false, err
+	}
+	result, err := tx.ExecContext(ctx, `
+		INSERT INTO items (name, category_id, image_name)
+		VALUES (?, ?, ?)
+	`, item.Name, categoryID, item.ImageName)
+	if err != nil {
+		return false, fmt.Errorf("failed to insert item: %w", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return false, fmt.Errorf("failed to get item id: %w", err)
+	}
+	if _, err = tx.ExecContext(ctx, "INSERT OR REPLACE INTO legacy_items (key, item_id) VALUES (?, ?)", key, id); err != nil {
+		return false, fmt.Errorf("failed to record legacy item: %w", err)
+	}
+	if err = tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	item.ID = int(id)
+	return true, nil
+}
+
+// streamPageSize is the number of items Stream reads with a query.
+const streamPageSize = 500
+
+// Stream calls fn with each item whose name contains keyword and which is in the category named category,
+// in the order of their IDs. An empty keyword or category doesn't filter the items.
+// The items are read in pages by ID, so that exporting a large catalog doesn't load it into memory.
+// No query is open while fn runs, since a reader blocks the writers of the database until it is done,
+// and fn may be as slow as the client an export is written to.
+// It stops at the first error returned by fn and returns it.
+func (i *itemRepository) Stream(ctx context.Context, keyword, category string, fn func(Item) error) error {
+	return i.stream(ctx, keyword, category, streamPageSize, nil, fn)
+}
+
+// streamObserved is Stream which calls observe with the duration of each page query,
+// so that the time fn takes isn't counted as the time of the database.
+func (i *itemRepository) streamObserved(ctx context.Context, keyword, category string, observe func(time.Duration), fn func(Item) error) error {
+	return i.stream(ctx, keyword, category, streamPageSize, observe, fn)
+}
+
+// stream reads the items in pages of pageSize. observe may be nil.
+func (i *itemRepository) stream(ctx context.Context, keyword, category string, pageSize int, observe func(time.Duration), fn func(Item) error) error {
+	lastID := 0
+	for {
+		start := time.Now()
+		items, err := i.streamPage(ctx, keyword, category, lastID, pageSize)
+		if observe != nil {
+			observe(time.Since(start))
+		}
+		if err != nil {
+			return err
+		}
+		for _, item := range items {
+			if err := fn(item); err != nil {
+				return err
+			}
+		}
+		if len(items) < pageSize {
+			return nil
+		}
+		lastID = items[len(items)-1].ID
+	}
+}
+
+// streamPage returns up to limit items after the item of lastID.
+func (i *itemRepository) streamPage(ctx context.Context, keyword, category string, lastID, limit int) ([]Item, error) {
+	rows, err := i.db.QueryContext(ctx, `
+		SELECT i.id, i.name, c.name AS category, i.image_name
+		FROM items i
+		JOIN categories c ON i.category_id = c.id
+		WHERE i.name LIKE ? AND (? = '' OR c.name = ?) AND i.id > ?
+		ORDER BY i.id
+		LIMIT ?
+	`, "%"+keyword+"%", category, category, lastID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query items: %w", err)
+	}
+	defer rows.Close()
+
+	items := make([]Item, 0, limit)
+	for rows.Next() {
+		var item Item
+		if err := rows.Scan(&item.ID, &item.Name, &item.Category, &item.ImageName); err != nil {
+			return nil, fmt.Errorf("failed to scan item: %w", err)
+		}
+		items = append(items, item)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during iteration: %w", err)
+	}
+	return items, nil
+}
+
 // Get returns a specific item from the repository.
 func (i *itemRepository) Get(ctx context.Context, id string) (*Item, error) {
     if id == "" {
@@ -195,20 +583,31 @@ func (i *itemRepository) Search(ctx context.Context, keyword string) ([]Item, er
 
 // returns the category ID for a given category name
 func (i *itemRepository) GetCategoryID(ctx context.Context, categoryName string) (int, error) {
+	return getOrCreateCategoryID(ctx, i.db, categoryName)
+}
+
+// dbExecutor is either *sql.DB or *sql.Tx, so that queries can be run in a transaction or not.
+type dbExecutor interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// getOrCreateCategoryID returns the category ID for a given category name, creating the category if not found.
+func getOrCreateCategoryID(ctx context.Context, db dbExecutor, categoryName string) (int, error) {
 	var id int
-	err := i.db.QueryRowContext(ctx, "SELECT id FROM categories WHERE name = ?", categoryName).Scan(&id)
+	err := db.QueryRowContext(ctx, "SELECT id FROM categories WHERE name = ?", categoryName).Scan(&id)
 	if err == sql.ErrNoRows {
 		// insert a new category if not found
-        result, insertErr := i.db.ExecContext(ctx, "INSERT INTO categories (name) VALUES (?)", categoryName)
-        if insertErr != nil {
-            return 0, fmt.Errorf("failed to create new category: %w", insertErr)
-        }
-        // get the new category id
-        newID, idErr := result.LastInsertId()
-        if idErr != nil {
-            return 0, fmt.Errorf("failed to get new category id: %w", idErr)
-        }
-        return int(newID), nil
+		result, insertErr := db.ExecContext(ctx, "INSERT INTO categories (name) VALUES (?)", categoryName)
+		if insertErr != nil {
+			return 0, fmt.Errorf("failed to create new category: %w", insertErr)
+		}
+		// get the new category id
+		newID, idErr := result.LastInsertId()
+		if idErr != nil {
+			return 0, fmt.Errorf("failed to get new category id: %w", idErr)
+		}
+		return int(newID), nil
 	}
 	if err != nil {
 		return 0, fmt.Errorf("failed to get category id: %w", err)
@@ -227,4 +626,263 @@ func (i *itemRepository) GetCategoryName(ctx context.Context, categoryID int) (s
         return "", fmt.Errorf("failed to get category name: %w", err)
     }
     return name, nil
-}
\ No newline at end of file
+}
+
+// SaveImage saves the information of a stored image.
+// The same image can be uploaded more than once, so the existing record is overwritten.
+func (i *itemRepository) SaveImage(ctx context.Context, info *ImageInfo) error {
+	if info == nil || info.Name == "" {
+		return errInvalidInput
+	}
+
+	// SQLite integers are signed, so the hash is stored as its two's complement
+	_, err := i.db.ExecContext(ctx, `
+		INSERT INTO images (name, phash, format, width, height, size, sha256, dominant_colors)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (name) DO UPDATE SET
+			phash = excluded.phash,
+			format = excluded.format,
+			width = excluded.width,
+			height = excluded.height,
+			size = excluded.size,
+			sha256 = excluded.sha256,
+			dominant_colors = excluded.dominant_colors
+	`, info.Name, int64(info.PHash), info.Format, info.Width, info.Height, info.Size, info.SHA256,
+		strings.Join(info.DominantColors, ","))
+	if err != nil {
+		return fmt.Errorf("failed to save image: %w", err)
+	}
+	return nil
+}
+
+// GetImageInfo returns the information of a stored image.
+func (i *itemRepository) GetImageInfo(ctx context.Context, name string) (*ImageInfo, error) {
+	if name == "" {
+		return nil, errInvalidInput
+	}
+
+	var info ImageInfo
+	var phash int64
+	var colors string
+	err := i.db.QueryRowContext(ctx, `
+		SELECT name, phash, format, width, height, size, sha256, dominant_colors
+		FROM images
+		WHERE name = ?
+	`, name).Scan(&info.Name, &phash, &info.Format, &info.Width, &info.Height, &info.Size, &info.SHA256, &colors)
+
+	if err == sql.ErrNoRows {
+		return nil, errImageNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get image: %w", err)
+	}
+
+	info.PHash = uint64(phash)
+	info.DominantColors = []string{}
+	if colors != "" {
+		info.DominantColors = strings.Split(colors, ",")
+	}
+	return &info, nil
+}
+
+// ListImageHashes returns all items whose image has a perceptual hash.
+func (i *itemRepository) ListImageHashes(ctx context.Context) ([]ItemImageHash, error) {
+	rows, err := i.db.QueryContext(ctx, `
+		SELECT i.id, i.name, c.name AS category, i.image_name, img.phash
+		FROM items i
+		JOIN categories c ON i.category_id = c.id
+		JOIN images img ON i.image_name = img.name
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query image hashes: %w", err)
+	}
+	defer rows.Close()
+
+	var hashes []ItemImageHash
+	for rows.Next() {
+		var h ItemImageHash
+		var phash int64
+		if err := rows.Scan(&h.ID, &h.Name, &h.Category, &h.ImageName, &phash); err != nil {
+			return nil, fmt.Errorf("failed to scan image hash: %w", err)
+		}
+		h.PHash = uint64(phash)
+		hashes = append(hashes, h)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during iteration: %w", err)
+	}
+
+	return hashes, nil
+}
+
+// LatestSchemaVersion is the schema version after all migrations are applied.
+var LatestSchemaVersion = len(migrations)
+
+// Delete deletes an item. Its image is left in the image directory, since other items may use it.
+func (i *itemRepository) Delete(ctx context.Context, id int) error {
+	result, err := i.db.ExecContext(ctx, "DELETE FROM items WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete item: %w", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to delete item: %w", err)
+	}
+	if n == 0 {
+		return errItemNotFound
+	}
+	return nil
+}
+
+// ListCategories returns all categories ordered by name, with their number of items.
+// Categories are identified by name, so the rows of the same name, which older versions of items.sql
+// inserted on every start, are listed once with the lowest ID.
+func (i *itemRepository) ListCategories(ctx context.Context) ([]Category, error) {
+	rows, err := i.db.QueryContext(ctx, `
+		SELECT MIN(c.id), c.name, COUNT(i.id)
+		FROM categories c
+		LEFT JOIN items i ON i.category_id = c.id
+		GROUP BY c.name
+		ORDER BY c.name
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query categories: %w", err)
+	}
+	defer rows.Close()
+
+	var categories []Category
+	for rows.Next() {
+		var c Category
+		if err := rows.Scan(&c.ID, &c.Name, &c.Items); err != nil {
+			return nil, fmt.Errorf("failed to scan category: %w", err)
+		}
+		categories = append(categories, c)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during iteration: %w", err)
+	}
+	return categories, nil
+}
+
+// RenameCategory renames a category, and so the category of its items.
+func (i *itemRepository) RenameCategory(ctx context.Context, name, newName string) error {
+	if name == "" || newName == "" {
+		return errInvalidInput
+	}
+	var exists int
+	err := i.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM categories WHERE name = ?", newName).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("failed to get category: %w", err)
+	}
+	if exists > 0 {
+		return fmt.Errorf("%w: category %q already exists", errInvalidInput, newName)
+	}
+
+	result, err := i.db.ExecContext(ctx, "UPDATE categories SET name = ? WHERE name = ?", newName, name)
+	if err != nil {
+		return fmt.Errorf("failed to rename category: %w", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to rename category: %w", err)
+	}
+	if n == 0 {
+		return errCategoryNotFound
+	}
+	return nil
+}
+
+// DeleteCategory deletes a category including the rows of the same name.
+// A category with items can't be deleted, so that no item is deleted by accident.
+func (i *itemRepository) DeleteCategory(ctx context.Context, name string) (err error) {
+	tx, err := i.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var rows, items int
+	err = tx.QueryRowContext(ctx, `
+		SELECT COUNT(DISTINCT c.id), COUNT(i.id)
+		FROM categories c
+		LEFT JOIN items i ON i.category_id = c.id
+		WHERE c.name = ?
+	`, name).Scan(&rows, &items)
+	if err != nil {
+		return fmt.Errorf("failed to get category: %w", err)
+	}
+	if rows == 0 {
+		return errCategoryNotFound
+	}
+	if items > 0 {
+		return fmt.Errorf("%w: %d items are in %q", errCategoryInUse, items, name)
+	}
+
+	if _, err = tx.ExecContext(ctx, "DELETE FROM categories WHERE name = ?", name); err != nil {
+		return fmt.Errorf("failed to delete category: %w", err)
+	}
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// SchemaVersion returns the number of migrations applied to the database.
+func (i *itemRepository) SchemaVersion(ctx context.Context) (int, error) {
+	var version int
+	if err := i.db.QueryRowContext(ctx, "PRAGMA user_version").Scan(&version); err != nil {
+		return 0, fmt.Errorf("failed to get schema version: %w", err)
+	}
+	return version, nil
+}
+
+// Vacuum rebuilds the database file, reclaiming the pages of deleted rows.
+func (i *itemRepository) Vacuum(ctx context.Context) error {
+	if _, err := i.db.ExecContext(ctx, "VACUUM"); err != nil {
+		return fmt.Errorf("failed to vacuum database: %w", err)
+	}
+	return nil
+}
+
+// Backup writes a consistent copy of the database to path, which must not exist.
+// It can run while the server is serving requests.
+func (i *itemRepository) Backup(ctx context.Context, path string) error {
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("%w: %s already exists", errInvalidInput, path)
+	}
+	if _, err := i.db.ExecContext(ctx, "VACUUM INTO ?", path); err != nil {
+		return fmt.Errorf("failed to back up database: %w", err)
+	}
+	return nil
+}
+
+// CatalogStats returns the statistics of the catalog and its database.
+func (i *itemRepository) CatalogStats(ctx context.Context) (*CatalogStats, error) {
+	var stats CatalogStats
+	var pageSize, pageCount, freePages int64
+	err := i.db.QueryRowContext(ctx, `
+		SELECT
+			(SELECT COUNT(*) FROM items),
+			(SELECT COUNT(DISTINCT name) FROM categories),
+			(SELECT COUNT(*) FROM images),
+			(SELECT page_size FROM pragma_page_size()),
+			(SELECT page_count FROM pragma_page_count()),
+			(SELECT freelist_count FROM pragma_freelist_count())
+	`).Scan(&stats.Items, &stats.Categories, &stats.Images, &pageSize, &pageCount, &freePages)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get catalog stats: %w", err)
+	}
+	stats.DatabaseBytes = pageSize * pageCount
+	stats.FreeBytes = pageSize * freePages
+
+	if stats.SchemaVersion, err = i.SchemaVersion(ctx); err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
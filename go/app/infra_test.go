@@ -0,0 +1,439 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func TestImageInfoRepository(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping e2e test")
+	}
+
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "mercari.sqlite3"))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	schema, err := os.ReadFile("../db/items.sql")
+	if err != nil {
+		t.Fatalf("failed to read items.sql: %v", err)
+	}
+	if _, err := db.Exec(string(schema)); err != nil {
+		t.Fatalf("failed to execute items.sql: %v", err)
+	}
+	// migrations must be safe to apply more than once
+	for range 2 {
+		if err := migrate(context.Background(), db); err != nil {
+			t.Fatalf("failed to migrate database: %v", err)
+		}
+	}
+
+	ctx := context.Background()
+	repo := &itemRepository{db: db}
+
+	want := &ImageInfo{
+		Name:           "abc.jpg",
+		Format:         "jpeg",
+		Width:          640,
+		Height:         480,
+		Size:           12345,
+		SHA256:         "abc",
+		DominantColors: []string{"#ffffff", "#000000"},
+		PHash:          1 << 63, // doesn't fit in a signed integer
+	}
+	if err := repo.SaveImage(ctx, want); err != nil {
+		t.Fatalf("failed to save image: %v", err)
+	}
+	got, err := repo.GetImageInfo(ctx, "abc.jpg")
+	if err != nil {
+		t.Fatalf("failed to get image info: %v", err)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected image info (-want +got):\n%s", diff)
+	}
+
+	if _, err := repo.GetImageInfo(ctx, "missing.jpg"); err != errImageNotFound {
+		t.Errorf("expected errImageNotFound, got %v", err)
+	}
+}
+
+func TestListByCategory(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping e2e test")
+	}
+
+	db, closers, err := setupDB(t)
+	if err != nil {
+		t.Fatalf("failed to set up database: %v", err)
+	}
+	t.Cleanup(func() {
+		for _, c := range closers {
+			c()
+		}
+	})
+
+	ctx := context.Background()
+	repo := &itemRepository{db: db}
+	for _, item := range []*Item{
+		{Name: "jacket", Category: "fashion", ImageName: "default.jpg"},
+		{Name: "iPhone", Category: "phone", ImageName: "default.jpg"},
+		{Name: "boots", Category: "fashion", ImageName: "default.jpg"},
+	} {
+		if err := repo.Insert(ctx, item); err != nil {
+			t.Fatalf("failed to insert item: %v", err)
+		}
+	}
+
+	got, err := repo.ListByCategory(ctx, "fashion")
+	if err != nil {
+		t.Fatalf("failed to list items: %v", err)
+	}
+	want := []Item{
+		{ID: 1, Name: "jacket", Category: "fashion", ImageName: "default.jpg"},
+		{ID: 3, Name: "boots", Category: "fashion", ImageName: "default.jpg"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected items (-want +got):\n%s", diff)
+	}
+}
+
+func TestStream(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping e2e test")
+	}
+
+	db, closers, err := setupDB(t)
+	if err != nil {
+		t.Fatalf("failed to set up database: %v", err)
+	}
+	t.Cleanup(func() {
+		for _, c := range closers {
+			c()
+		}
+	})
+
+	ctx := context.Background()
+	repo := &itemRepository{db: db}
+	for _, item := range []*Item{
+		{Name: "jacket", Category: "fashion", ImageName: "default.jpg"},
+		{Name: "iPhone", Category: "phone", ImageName: "default.jpg"},
+		{Name: "jacket 2", Category: "fashion", ImageName: "default.jpg"},
+	} {
+		if err := repo.Insert(ctx, item); err != nil {
+			t.Fatalf("failed to insert item: %v", err)
+		}
+	}
+
+	collect := func(keyword, category string) ([]Item, error) {
+		var items []Item
+		err := repo.Stream(ctx, keyword, category, func(item Item) error {
+			items = append(items, item)
+			return nil
+		})
+		return items, err
+	}
+
+	got, err := collect("jack", "")
+	if err != nil {
+		t.Fatalf("failed to stream items: %v", err)
+	}
+	want := []Item{
+		{ID: 1, Name: "jacket", Category: "fashion", ImageName: "default.jpg"},
+		{ID: 3, Name: "jacket 2", Category: "fashion", ImageName: "default.jpg"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected items (-want +got):\n%s", diff)
+	}
+
+	got, err = collect("", "")
+	if err != nil {
+		t.Fatalf("failed to stream items: %v", err)
+	}
+	if len(got) != 3 {
+		t.Errorf("expected every item, got %d", len(got))
+	}
+
+	got, err = collect("", "phone")
+	if err != nil {
+		t.Fatalf("failed to stream items: %v", err)
+	}
+	want = []Item{{ID: 2, Name: "iPhone", Category: "phone", ImageName: "default.jpg"}}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected items (-want +got):\n%s", diff)
+	}
+
+	got, err = collect("2", "fashion")
+	if err != nil {
+		t.Fatalf("failed to stream items: %v", err)
+	}
+	want = []Item{{ID: 3, Name: "jacket 2", Category: "fashion", ImageName: "default.jpg"}}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected items (-want +got):\n%s", diff)
+	}
+
+	// an error of fn stops the stream
+	errStop := errors.New("stop")
+	calls := 0
+	err = repo.Stream(ctx, "", "", func(Item) error {
+		calls++
+		return errStop
+	})
+	if !errors.Is(err, errStop) || calls != 1 {
+		t.Errorf("expected to stop after the first item with %v, got %v after %d items", errStop, err, calls)
+	}
+}
+
+func TestStreamConcurrentWrites(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping e2e test")
+	}
+
+	db, closers, err := setupDB(t)
+	if err != nil {
+		t.Fatalf("failed to set up database: %v", err)
+	}
+	t.Cleanup(func() {
+		for _, c := range closers {
+			c()
+		}
+	})
+
+	ctx := context.Background()
+	repo := &itemRepository{db: db}
+	for _, name := range []string{"jacket", "boots", "glasses"} {
+		if err := repo.Insert(ctx, &Item{Name: name, Category: "fashion", ImageName: "default.jpg"}); err != nil {
+			t.Fatalf("failed to insert item: %v", err)
+		}
+	}
+
+	// an export must not keep the database locked while it writes to a slow client,
+	// so items can be inserted between the items of a stream, across pages
+	var ids []int
+	err = repo.stream(ctx, "", "", 2, nil, func(item Item) error {
+		ids = append(ids, item.ID)
+		if item.ID > 3 {
+			return nil
+		}
+		return repo.Insert(ctx, &Item{Name: "added during export", Category: "fashion", ImageName: "default.jpg"})
+	})
+	if err != nil {
+		t.Fatalf("failed to insert while streaming: %v", err)
+	}
+	// the items inserted after the page being written are streamed too
+	if diff := cmp.Diff([]int{1, 2, 3, 4, 5, 6}, ids); diff != "" {
+		t.Errorf("unexpected items (-want +got):\n%s", diff)
+	}
+}
+
+func TestInsertLegacyItem(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping e2e test")
+	}
+
+	db, closers, err := setupDB(t)
+	if err != nil {
+		t.Fatalf("failed to set up database: %v", err)
+	}
+	t.Cleanup(func() {
+		for _, c := range closers {
+			c()
+		}
+	})
+	// the table of legacy items is created by a migration
+	if _, err := db.Exec(migrations[1]); err != nil {
+		t.Fatalf("failed to create legacy_items: %v", err)
+	}
+
+	ctx := context.Background()
+	repo := &itemRepository{db: db}
+	if _, err := repo.GetLegacyItemID(ctx, "key"); !errors.Is(err, errItemNotFound) {
+		t.Fatalf("expected the key not to be imported yet, got %v", err)
+	}
+	item := &Item{Name: "jacket", Category: "fashion", ImageName: "default.jpg"}
+	inserted, err := repo.InsertLegacyItem(ctx, "key", item)
+	if err != nil || !inserted {
+		t.Fatalf("expected the item to be inserted, got %v, %v", inserted, err)
+	}
+	if id, err := repo.GetLegacyItemID(ctx, "key"); err != nil || id != item.ID {
+		t.Errorf("expected the key to refer to item %d, got %d, %v", item.ID, id, err)
+	}
+
+	// a rerun finds the item inserted by the first run
+	again := &Item{Name: "jacket", Category: "fashion", ImageName: "default.jpg"}
+	inserted, err = repo.InsertLegacyItem(ctx, "key", again)
+	if err != nil || inserted {
+		t.Fatalf("expected the item to be skipped, got %v, %v", inserted, err)
+	}
+	if again.ID != item.ID {
+		t.Errorf("expected the existing item %d, got %d", item.ID, again.ID)
+	}
+
+	// a key whose item was deleted without cascading, as before foreign keys were enforced, is imported again
+	if _, err := db.Exec("DELETE FROM items WHERE id = ?", item.ID); err != nil {
+		t.Fatalf("failed to delete item: %v", err)
+	}
+	if _, err := repo.GetLegacyItemID(ctx, "key"); !errors.Is(err, errItemNotFound) {
+		t.Fatalf("expected the key of a deleted item not to be found, got %v", err)
+	}
+	item = &Item{Name: "jacket", Category: "fashion", ImageName: "default.jpg"}
+	inserted, err = repo.InsertLegacyItem(ctx, "key", item)
+	if err != nil || !inserted {
+		t.Fatalf("expected the item to be inserted again, got %v, %v", inserted, err)
+	}
+
+	got, err := repo.ListByCategory(ctx, "fashion")
+	if err != nil {
+		t.Fatalf("failed to list items: %v", err)
+	}
+	want := []Item{{ID: item.ID, Name: "jacket", Category: "fashion", ImageName: "default.jpg"}}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected items (-want +got):\n%s", diff)
+	}
+}
+
+func TestCategories(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping e2e test")
+	}
+
+	db, closers, err := setupDB(t)
+	if err != nil {
+		t.Fatalf("failed to set up database: %v", err)
+	}
+	t.Cleanup(func() {
+		for _, c := range closers {
+			c()
+		}
+	})
+
+	ctx := context.Background()
+	repo := &itemRepository{db: db}
+	for _, item := range []*Item{
+		{Name: "jacket", Category: "fashion", ImageName: "default.jpg"},
+		{Name: "iPhone", Category: "phone", ImageName: "default.jpg"},
+	} {
+		if err := repo.Insert(ctx, item); err != nil {
+			t.Fatalf("failed to insert item: %v", err)
+		}
+	}
+
+	if err := repo.RenameCategory(ctx, "phone", "fashion"); !errors.Is(err, errInvalidInput) {
+		t.Errorf("expected renaming to an existing category to fail, got %v", err)
+	}
+	if err := repo.RenameCategory(ctx, "phone", "smartphone"); err != nil {
+		t.Fatalf("failed to rename category: %v", err)
+	}
+	if err := repo.DeleteCategory(ctx, "smartphone"); !errors.Is(err, errCategoryInUse) {
+		t.Errorf("expected a category with items not to be deleted, got %v", err)
+	}
+
+	item, err := repo.Search(ctx, "iPhone")
+	if err != nil || len(item) != 1 {
+		t.Fatalf("failed to find item: %v, %v", item, err)
+	}
+	if err := repo.Delete(ctx, item[0].ID); err != nil {
+		t.Fatalf("failed to delete item: %v", err)
+	}
+	if err := repo.Delete(ctx, item[0].ID); !errors.Is(err, errItemNotFound) {
+		t.Errorf("expected a deleted item not to be found, got %v", err)
+	}
+	if err := repo.DeleteCategory(ctx, "smartphone"); err != nil {
+		t.Fatalf("failed to delete category: %v", err)
+	}
+	if err := repo.DeleteCategory(ctx, "smartphone"); !errors.Is(err, errCategoryNotFound) {
+		t.Errorf("expected a deleted category not to be found, got %v", err)
+	}
+
+	got, err := repo.ListCategories(ctx)
+	if err != nil {
+		t.Fatalf("failed to list categories: %v", err)
+	}
+	want := []Category{{Name: "fashion", Items: 1}}
+	if diff := cmp.Diff(want, got, cmpopts.IgnoreFields(Category{}, "ID")); diff != "" {
+		t.Errorf("unexpected categories (-want +got):\n%s", diff)
+	}
+}
+
+func TestOpenAdminRepository(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping e2e test")
+	}
+
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "mercari.sqlite3")
+	// a wrong path doesn't create an empty database
+	if _, err := OpenAdminRepository(path); err == nil {
+		t.Fatalf("expected a missing database not to be opened")
+	}
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected a missing database not to be created, got %v", err)
+	}
+
+	if err := os.WriteFile(path, nil, 0644); err != nil {
+		t.Fatalf("failed to create database: %v", err)
+	}
+	repo, err := OpenAdminRepository(path + "?_busy_timeout=5000")
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() { repo.Close() })
+
+	// opening doesn't touch the schema, so that it can be backed up before migrating
+	version, err := repo.SchemaVersion(ctx)
+	if err != nil || version != 0 {
+		t.Fatalf("expected schema version 0 before migrating, got %d, %v", version, err)
+	}
+	if _, err := repo.ListCategories(ctx); err == nil {
+		t.Errorf("expected no tables before migrating")
+	}
+
+	for range 2 {
+		if err := repo.Migrate(ctx, "../db/items.sql"); err != nil {
+			t.Fatalf("failed to migrate database: %v", err)
+		}
+	}
+	version, err = repo.SchemaVersion(ctx)
+	if err != nil || version != LatestSchemaVersion {
+		t.Errorf("expected schema version %d after migrating, got %d, %v", LatestSchemaVersion, version, err)
+	}
+
+	// older versions of items.sql inserted the initial categories on every start,
+	// and the duplicate rows count as one category as in the list of categories
+	if _, err := repo.(*itemRepository).db.ExecContext(ctx, "INSERT INTO categories (name) VALUES ('phone')"); err != nil {
+		t.Fatalf("failed to insert a duplicate category: %v", err)
+	}
+	categories, err := repo.ListCategories(ctx)
+	if err != nil {
+		t.Fatalf("failed to list categories: %v", err)
+	}
+	stats, err := repo.CatalogStats(ctx)
+	if err != nil {
+		t.Fatalf("failed to get catalog stats: %v", err)
+	}
+	if len(categories) != 2 || stats.Categories != len(categories) {
+		t.Errorf("expected 2 categories in both the list and the stats, got %d and %d", len(categories), stats.Categories)
+	}
+
+	// deleting an imported item removes its key, since foreign keys are enforced
+	item := &Item{Name: "jacket", Category: "fashion", ImageName: "default.jpg"}
+	if _, err := repo.(*itemRepository).InsertLegacyItem(ctx, "key", item); err != nil {
+		t.Fatalf("failed to insert legacy item: %v", err)
+	}
+	if err := repo.Delete(ctx, item.ID); err != nil {
+		t.Fatalf("failed to delete item: %v", err)
+	}
+	var keys int
+	if err := repo.(*itemRepository).db.QueryRowContext(ctx, "SELECT COUNT(*) FROM legacy_items").Scan(&keys); err != nil || keys != 0 {
+		t.Errorf("expected the key of the deleted item to be removed, got %d, %v", keys, err)
+	}
+}
@@ -0,0 +1,172 @@
+package app
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+)
+
+// importStatusSkipped is the status of a legacy item which was imported by an earlier run.
+const importStatusSkipped = "skipped"
+
+// legacyItem is an item of items.json, in which items were stored before SQLite.
+type legacyItem struct {
+	ID        int    `json:"id"`
+	Name      string `json:"name"`
+	Category  string `json:"category"`
+	ImageName string `json:"image_name"` // name of the image in the legacy image directory
+}
+
+// key identifies the legacy item. The key is derived from all the fields including the ID, so that
+// a copy of the same items.json isn't imported twice, while items which only share a name are.
+func (it legacyItem) key() string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%d\x00%s\x00%s\x00%s", it.ID, it.Name, it.Category, it.ImageName)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// LegacyImportResult is the result of importing a legacy item.
+type LegacyImportResult struct {
+	LegacyID  int          `json:"legacy_id"`
+	Status    string       `json:"status"`
+	ID        int          `json:"id,omitempty"` // ID of the imported item
+	ImageName string       `json:"image_name,omitempty"`
+	Warning   string       `json:"warning,omitempty"` // e.g. the image is missing and the default image is used
+	Errors    []FieldError `json:"errors,omitempty"`
+}
+
+// LegacyImportResponse is the report of a legacy import.
+type LegacyImportResponse struct {
+	Total    int                  `json:"total"`
+	Imported int                  `json:"imported"`
+	Skipped  int                  `json:"skipped"`
+	Invalid  int                  `json:"invalid"`
+	Failed   int                  `json:"failed"`
+	Items    []LegacyImportResult `json:"items"`
+}
+
+// LegacyImporter imports the items.json of the JSON file repository into the database.
+// Each item is validated as AddItem would, its category is created if missing, and its image is
+// re-encoded and stored in ImageDir under its new hash like an uploaded image.
+// It is idempotent: the items imported by an earlier run are skipped.
+type LegacyImporter struct {
+	Repo LegacyRepository
+	// ImageDir is the directory the images are stored in.
+	ImageDir string
+	// LegacyImageDir is the directory the images of items.json are read from.
+	LegacyImageDir string
+}
+
+// Import imports the items of the items.json read from r.
+// It returns an error only if r isn't an items.json; the result of each item is reported in the response.
+func (im *LegacyImporter) Import(ctx context.Context, r io.Reader) (*LegacyImportResponse, error) {
+	var file struct {
+		Items []legacyItem `json:"items"`
+	}
+	// an empty file, such as the one left in go/app, has no items
+	if err := json.NewDecoder(r).Decode(&file); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("%w: failed to decode items.json: %w", errInvalidImport, err)
+	}
+
+	resp := &LegacyImportResponse{Total: len(file.Items), Items: []LegacyImportResult{}}
+	for _, legacy := range file.Items {
+		result := im.importItem(ctx, legacy)
+		switch result.Status {
+		case importStatusImported:
+			resp.Imported++
+		case importStatusSkipped:
+			resp.Skipped++
+		case importStatusInvalid:
+			resp.Invalid++
+		case importStatusFailed:
+			resp.Failed++
+		}
+		resp.Items = append(resp.Items, result)
+	}
+	return resp, nil
+}
+
+func (im *LegacyImporter) importItem(ctx context.Context, legacy legacyItem) LegacyImportResult {
+	result := LegacyImportResult{LegacyID: legacy.ID}
+
+	req := &AddItemRequest{Name: legacy.Name, Category: legacy.Category}
+	if violations := validateStruct(req); len(violations) > 0 {
+		result.Status = importStatusInvalid
+		result.Errors = violations
+		return result
+	}
+
+	// the image of an item imported by an earlier run isn't stored again
+	key := legacy.key()
+	id, err := im.Repo.GetLegacyItemID(ctx, key)
+	if err == nil {
+		result.ID = id
+		result.Status = importStatusSkipped
+		return result
+	}
+	if !errors.Is(err, errItemNotFound) {
+		slog.ErrorContext(ctx, "failed to get legacy item: ", "error", err, "legacy_id", legacy.ID)
+		result.Status = importStatusFailed
+		result.Errors = []FieldError{{Message: "failed to check if the item was imported"}}
+		return result
+	}
+
+	imageName, warning, err := im.importImage(ctx, legacy.ImageName)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to import image: ", "error", err, "legacy_id", legacy.ID)
+		result.Status = importStatusFailed
+		result.Errors = []FieldError{{Field: "image_name", Message: "failed to store the image"}}
+		return result
+	}
+	result.ImageName = imageName
+	result.Warning = warning
+
+	item := &Item{Name: req.Name, Category: req.Category, ImageName: imageName}
+	// the key is checked again in the transaction, in case another import inserted the item meanwhile
+	inserted, err := im.Repo.InsertLegacyItem(ctx, key, item)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to import legacy item: ", "error", err, "legacy_id", legacy.ID)
+		result.Status = importStatusFailed
+		result.Errors = []FieldError{{Message: "failed to insert the item"}}
+		return result
+	}
+	result.ID = item.ID
+	result.Status = importStatusImported
+	if !inserted {
+		result.Status = importStatusSkipped
+	}
+	return result
+}
+
+// importImage stores the legacy image named name and returns its new name.
+// A missing image is replaced with the default image and reported as a warning, since items.json
+// was often copied without its images.
+func (im *LegacyImporter) importImage(ctx context.Context, name string) (newName, warning string, err error) {
+	if name == "" || name == "default.jpg" {
+		return "default.jpg", "", nil
+	}
+	path, err := buildImagePath(im.LegacyImageDir, name)
+	if err != nil {
+		return "default.jpg", fmt.Sprintf("image %q isn't in %s, the default image is used", name, im.LegacyImageDir), nil
+	}
+
+	src, err := os.Open(path)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to open image: %w", err)
+	}
+	defer src.Close()
+	info, err := storeImage(im.ImageDir, src)
+	if err != nil {
+		return "", "", err
+	}
+	if err := im.Repo.SaveImage(ctx, info); err != nil {
+		return "", "", err
+	}
+	return info.Name, "", nil
+}
@@ -0,0 +1,197 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"go.uber.org/mock/gomock"
+)
+
+func TestLegacyImporter(t *testing.T) {
+	t.Parallel()
+
+	type wants struct {
+		resp *LegacyImportResponse
+		err  bool
+	}
+
+	cases := map[string]struct {
+		body     string
+		injector func(m *MockLegacyRepository)
+		wants
+	}{
+		"ok: items are imported with their categories": {
+			body: `{"items": [
+				{"id": 1, "name": " jacket ", "category": "fashion", "image_name": ""},
+				{"id": 2, "name": "iPhone", "category": "phone", "image_name": "default.jpg"}
+			]}`,
+			injector: func(m *MockLegacyRepository) {
+				m.EXPECT().GetLegacyItemID(gomock.Any(), gomock.Any()).Return(0, errItemNotFound).Times(2)
+				m.EXPECT().InsertLegacyItem(gomock.Any(), gomock.Any(), &Item{Name: "jacket", Category: "fashion", ImageName: "default.jpg"}).
+					DoAndReturn(func(_ context.Context, _ string, item *Item) (bool, error) {
+						item.ID = 10
+						return true, nil
+					})
+				m.EXPECT().InsertLegacyItem(gomock.Any(), gomock.Any(), &Item{Name: "iPhone", Category: "phone", ImageName: "default.jpg"}).
+					DoAndReturn(func(_ context.Context, _ string, item *Item) (bool, error) {
+						item.ID = 11
+						return true, nil
+					})
+			},
+			wants: wants{
+				resp: &LegacyImportResponse{
+					Total: 2, Imported: 2,
+					Items: []LegacyImportResult{
+						{LegacyID: 1, Status: importStatusImported, ID: 10, ImageName: "default.jpg"},
+						{LegacyID: 2, Status: importStatusImported, ID: 11, ImageName: "default.jpg"},
+					},
+				},
+			},
+		},
+		// the image isn't stored again, so SaveImage isn't called
+		"ok: items imported by an earlier run are skipped": {
+			body: `{"items": [{"id": 1, "name": "jacket", "category": "fashion", "image_name": "jacket1.jpg"}]}`,
+			injector: func(m *MockLegacyRepository) {
+				m.EXPECT().GetLegacyItemID(gomock.Any(), gomock.Any()).Return(10, nil)
+			},
+			wants: wants{
+				resp: &LegacyImportResponse{
+					Total: 1, Skipped: 1,
+					Items: []LegacyImportResult{
+						{LegacyID: 1, Status: importStatusSkipped, ID: 10},
+					},
+				},
+			},
+		},
+		"ok: items imported by another import meanwhile are skipped": {
+			body: `{"items": [{"id": 1, "name": "jacket", "category": "fashion", "image_name": ""}]}`,
+			injector: func(m *MockLegacyRepository) {
+				m.EXPECT().GetLegacyItemID(gomock.Any(), gomock.Any()).Return(0, errItemNotFound)
+				m.EXPECT().InsertLegacyItem(gomock.Any(), gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ context.Context, _ string, item *Item) (bool, error) {
+						item.ID = 10
+						return false, nil
+					})
+			},
+			wants: wants{
+				resp: &LegacyImportResponse{
+					Total: 1, Skipped: 1,
+					Items: []LegacyImportResult{
+						{LegacyID: 1, Status: importStatusSkipped, ID: 10, ImageName: "default.jpg"},
+					},
+				},
+			},
+		},
+		"ok: a missing image is replaced with the default image": {
+			body: `{"items": [{"id": 1, "name": "jacket", "category": "fashion", "image_name": "missing.jpg"}]}`,
+			injector: func(m *MockLegacyRepository) {
+				m.EXPECT().GetLegacyItemID(gomock.Any(), gomock.Any()).Return(0, errItemNotFound)
+				m.EXPECT().InsertLegacyItem(gomock.Any(), gomock.Any(), &Item{Name: "jacket", Category: "fashion", ImageName: "default.jpg"}).
+					Return(true, nil)
+			},
+			wants: wants{
+				resp: &LegacyImportResponse{
+					Total: 1, Imported: 1,
+					Items: []LegacyImportResult{
+						{LegacyID: 1, Status: importStatusImported, ImageName: "default.jpg", Warning: "image \"missing.jpg\" isn't in ../images, the default image is used"},
+					},
+				},
+			},
+		},
+		"ok: invalid and failed items are reported": {
+			body: `{"items": [
+				{"id": 1, "name": "", "category": "fashion"},
+				{"id": 2, "name": "jacket", "category": "fashion"}
+			]}`,
+			injector: func(m *MockLegacyRepository) {
+				m.EXPECT().GetLegacyItemID(gomock.Any(), gomock.Any()).Return(0, errItemNotFound)
+				m.EXPECT().InsertLegacyItem(gomock.Any(), gomock.Any(), gomock.Any()).Return(false, errors.New("database is locked"))
+			},
+			wants: wants{
+				resp: &LegacyImportResponse{
+					Total: 2, Invalid: 1, Failed: 1,
+					Items: []LegacyImportResult{
+						{LegacyID: 1, Status: importStatusInvalid},
+						{LegacyID: 2, Status: importStatusFailed, ImageName: "default.jpg"},
+					},
+				},
+			},
+		},
+		"ok: empty file": {
+			body:     "",
+			injector: func(m *MockLegacyRepository) {},
+			wants: wants{
+				resp: &LegacyImportResponse{Items: []LegacyImportResult{}},
+			},
+		},
+		"ng: not an items.json": {
+			body:     `[{"id": 1}]`,
+			injector: func(m *MockLegacyRepository) {},
+			wants:    wants{err: true},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ctrl := gomock.NewController(t)
+			mockRepo := NewMockLegacyRepository(ctrl)
+			tc.injector(mockRepo)
+
+			im := &LegacyImporter{Repo: mockRepo, ImageDir: t.TempDir(), LegacyImageDir: "../images"}
+			got, err := im.Import(context.Background(), strings.NewReader(tc.body))
+			if tc.wants.err {
+				if !errors.Is(err, errInvalidImport) {
+					t.Fatalf("expected an invalid import error, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(tc.wants.resp, got, cmpopts.IgnoreFields(LegacyImportResult{}, "Errors")); diff != "" {
+				t.Errorf("unexpected response (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestLegacyImporterImage(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	mockRepo := NewMockLegacyRepository(ctrl)
+	var saved *ImageInfo
+	mockRepo.EXPECT().SaveImage(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, info *ImageInfo) error {
+		saved = info
+		return nil
+	})
+	mockRepo.EXPECT().GetLegacyItemID(gomock.Any(), gomock.Any()).Return(0, errItemNotFound)
+	mockRepo.EXPECT().InsertLegacyItem(gomock.Any(), gomock.Any(), gomock.Any()).Return(true, nil)
+
+	imageDir := t.TempDir()
+	im := &LegacyImporter{Repo: mockRepo, ImageDir: imageDir, LegacyImageDir: "../images"}
+	got, err := im.Import(context.Background(), strings.NewReader(
+		`{"items": [{"id": 1, "name": "jacket", "category": "fashion", "image_name": "jacket1.jpg"}]}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// the image is re-encoded and named by its new hash, as an uploaded image
+	if saved == nil || !hashedImageName.MatchString(saved.Name) {
+		t.Fatalf("expected the image to be saved under its hash, got %+v", saved)
+	}
+	if got.Items[0].ImageName != saved.Name {
+		t.Errorf("expected the item to refer to %s, got %s", saved.Name, got.Items[0].ImageName)
+	}
+	if _, err := os.Stat(filepath.Join(imageDir, saved.Name)); err != nil {
+		t.Errorf("expected the image to be copied: %v", err)
+	}
+}
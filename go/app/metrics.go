@@ -0,0 +1,400 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// This file exposes metrics in the Prometheus text format (version 0.0.4).
+// Only the few metric types this server needs are implemented, so that /metrics works
+// without the Prometheus client library or any other external service.
+
+var (
+	// httpDurationBuckets are the upper bounds in seconds of the HTTP latency histogram.
+	httpDurationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+	// dbDurationBuckets are the upper bounds in seconds of the database latency histogram.
+	dbDurationBuckets = []float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1}
+)
+
+// Metrics holds the metrics of the server. A nil *Metrics records nothing,
+// so that handlers can be used without metrics in tests.
+type Metrics struct {
+	httpRequests     *metricVec
+	httpDuration     *metricVec
+	httpInFlight     atomic.Int64
+	uploadedBytes    atomic.Int64
+	imageCacheHits   atomic.Int64
+	imageCacheMisses atomic.Int64
+	panics           atomic.Int64
+	dbQueryDuration  *metricVec
+	dbStats          func() sql.DBStats
+}
+
+// NewMetrics creates a new Metrics. dbStats reports the connection pool of the database
+// and may be nil if it isn't available.
+func NewMetrics(dbStats func() sql.DBStats) *Metrics {
+	return &Metrics{
+		httpRequests: newMetricVec("mercari_http_requests_total",
+			"Number of HTTP requests by method, route pattern and status code.",
+			"counter", nil, "method", "route", "status"),
+		httpDuration: newMetricVec("mercari_http_request_duration_seconds",
+			"Latency of HTTP requests by method and route pattern.",
+			"histogram", httpDurationBuckets, "method", "route"),
+		dbQueryDuration: newMetricVec("mercari_db_query_duration_seconds",
+			"Latency of database queries by ItemRepository method.",
+			"histogram", dbDurationBuckets, "method"),
+		dbStats: dbStats,
+	}
+}
+
+// observeRequest records a finished HTTP request.
+func (m *Metrics) observeRequest(method, route string, status int, d time.Duration) {
+	if m == nil {
+		return
+	}
+	m.httpRequests.add(1, method, route, strconv.Itoa(status))
+	m.httpDuration.observe(d.Seconds(), method, route)
+}
+
+// addUploadedBytes records the size of an uploaded image.
+func (m *Metrics) addUploadedBytes(n int64) {
+	if m == nil {
+		return
+	}
+	m.uploadedBytes.Add(n)
+}
+
+// observeImageCache records whether a client already had an image (hit) or it had to be sent (miss).
+func (m *Metrics) observeImageCache(hit bool) {
+	if m == nil {
+		return
+	}
+	if hit {
+		m.imageCacheHits.Add(1)
+	} else {
+		m.imageCacheMisses.Add(1)
+	}
+}
+
+// observePanic records a panic recovered in a handler.
+func (m *Metrics) observePanic() {
+	if m == nil {
+		return
+	}
+	m.panics.Add(1)
+}
+
+// observeQuery records the latency of a repository method.
+func (m *Metrics) observeQuery(method string, d time.Duration) {
+	if m == nil {
+		return
+	}
+	m.dbQueryDuration.observe(d.Seconds(), method)
+}
+
+// metricsContentType is the content type of the Prometheus text format.
+const metricsContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// ServeHTTP is a handler to expose the metrics for GET /metrics .
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", metricsContentType)
+	w.Header().Set("Cache-Control", "no-store")
+	m.WriteTo(w)
+}
+
+// WriteTo writes all metrics in the Prometheus text format.
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	cw := &countingWriter{w: w}
+
+	m.httpRequests.write(cw)
+	m.httpDuration.write(cw)
+	writeSingle(cw, "mercari_http_requests_in_flight", "Number of HTTP requests being served.", "gauge", float64(m.httpInFlight.Load()))
+	writeSingle(cw, "mercari_uploaded_bytes_total", "Total size of uploaded images in bytes.", "counter", float64(m.uploadedBytes.Load()))
+	writeSingle(cw, "mercari_image_cache_hits_total", "Number of image requests answered with 304 Not Modified.", "counter", float64(m.imageCacheHits.Load()))
+	writeSingle(cw, "mercari_image_cache_misses_total", "Number of image requests which sent the image.", "counter", float64(m.imageCacheMisses.Load()))
+	writeSingle(cw, "mercari_http_panics_total", "Number of panics recovered in HTTP handlers.", "counter", float64(m.panics.Load()))
+	m.dbQueryDuration.write(cw)
+
+	if m.dbStats != nil {
+		stats := m.dbStats()
+		writeSingle(cw, "mercari_db_max_open_connections", "Maximum number of open connections to the database.", "gauge", float64(stats.MaxOpenConnections))
+		writeSingle(cw, "mercari_db_open_connections", "Number of established connections to the database.", "gauge", float64(stats.OpenConnections))
+		writeSingle(cw, "mercari_db_in_use_connections", "Number of connections currently in use.", "gauge", float64(stats.InUse))
+		writeSingle(cw, "mercari_db_idle_connections", "Number of idle connections.", "gauge", float64(stats.Idle))
+		writeSingle(cw, "mercari_db_wait_count_total", "Number of times a query waited for a connection.", "counter", float64(stats.WaitCount))
+		writeSingle(cw, "mercari_db_wait_duration_seconds_total", "Total time spent waiting for a connection.", "counter", stats.WaitDuration.Seconds())
+	}
+	return cw.n, cw.err
+}
+
+// metricsMiddleware records the count, latency and in-flight number of HTTP requests.
+// It must wrap the ServeMux directly, since the route pattern is only known after the mux has run.
+func metricsMiddleware(next http.Handler, m *Metrics) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m.httpInFlight.Add(1)
+		defer m.httpInFlight.Add(-1)
+
+		start := time.Now()
+		rw := newResponseRecorder(w)
+		// deferred so that a request aborted by a panic is counted as well
+		defer func() {
+			m.observeRequest(r.Method, routeLabel(r), rw.status, time.Since(start))
+		}()
+		next.ServeHTTP(rw, r)
+	})
+}
+
+// routeLabel returns the route pattern matched by the ServeMux without the method,
+// e.g. "/items/{id}", so that the label doesn't explode with every item id.
+// Requests which no route matches, including those answered by the fallback handler, are "unmatched".
+func routeLabel(r *http.Request) string {
+	if r.Pattern == "" || r.Pattern == fallbackPattern {
+		return "unmatched"
+	}
+	if _, path, ok := strings.Cut(r.Pattern, " "); ok {
+		return path
+	}
+	return r.Pattern
+}
+
+// instrumentedRepository is an ItemRepository which records the latency of each method.
+type instrumentedRepository struct {
+	ItemRepository
+	metrics *Metrics
+}
+
+// instrumentRepository wraps repo to record the latency of its methods.
+func instrumentRepository(repo ItemRepository, m *Metrics) ItemRepository {
+	return &instrumentedRepository{ItemRepository: repo, metrics: m}
+}
+
+// observe records the time since start for method.
+func (i *instrumentedRepository) observe(method string, start time.Time) {
+	i.metrics.observeQuery(method, time.Since(start))
+}
+
+func (i *instrumentedRepository) Insert(ctx context.Context, item *Item) error {
+	defer i.observe("Insert", time.Now())
+	return i.ItemRepository.Insert(ctx, item)
+}
+
+func (i *instrumentedRepository) InsertBatch(ctx context.Context, items []*Item) error {
+	defer i.observe("InsertBatch", time.Now())
+	return i.ItemRepository.InsertBatch(ctx, items)
+}
+
+func (i *instrumentedRepository) List(ctx context.Context) ([]Item, error) {
+	defer i.observe("List", time.Now())
+	return i.ItemRepository.List(ctx)
+}
+
+func (i *instrumentedRepository) Get(ctx context.Context, id string) (*Item, error) {
+	defer i.observe("Get", time.Now())
+	return i.ItemRepository.Get(ctx, id)
+}
+
+func (i *instrumentedRepository) Search(ctx context.Context, keyword string) ([]Item, error) {
+	defer i.observe("Search", time.Now())
+	return i.ItemRepository.Search(ctx, keyword)
+}
+
+func (i *instrumentedRepository) ListByCategory(ctx context.Context, category string) ([]Item, error) {
+	defer i.observe("ListByCategory", time.Now())
+	return i.ItemRepository.ListByCategory(ctx, category)
+}
+
+// Stream records the latency of each page query instead of the whole stream,
+// which includes the time fn takes to write the items to a client that may be slow.
+// A repository which can't tell its queries apart, such as a mock, isn't measured.
+func (i *instrumentedRepository) Stream(ctx context.Context, keyword, category string, fn func(Item) error) error {
+	repo, ok := i.ItemRepository.(interface {
+		streamObserved(ctx context.Context, keyword, category string, observe func(time.Duration), fn func(Item) error) error
+	})
+	if !ok {
+		return i.ItemRepository.Stream(ctx, keyword, category, fn)
+	}
+	return repo.streamObserved(ctx, keyword, category, func(d time.Duration) {
+		i.metrics.observeQuery("Stream", d)
+	}, fn)
+}
+
+func (i *instrumentedRepository) Ping(ctx context.Context) error {
+	defer i.observe("Ping", time.Now())
+	return i.ItemRepository.Ping(ctx)
+}
+
+func (i *instrumentedRepository) GetCategoryID(ctx context.Context, categoryName string) (int, error) {
+	defer i.observe("GetCategoryID", time.Now())
+	return i.ItemRepository.GetCategoryID(ctx, categoryName)
+}
+
+func (i *instrumentedRepository) GetCategoryName(ctx context.Context, categoryID int) (string, error) {
+	defer i.observe("GetCategoryName", time.Now())
+	return i.ItemRepository.GetCategoryName(ctx, categoryID)
+}
+
+func (i *instrumentedRepository) SaveImage(ctx context.Context, info *ImageInfo) error {
+	defer i.observe("SaveImage", time.Now())
+	return i.ItemRepository.SaveImage(ctx, info)
+}
+
+func (i *instrumentedRepository) GetImageInfo(ctx context.Context, name string) (*ImageInfo, error) {
+	defer i.observe("GetImageInfo", time.Now())
+	return i.ItemRepository.GetImageInfo(ctx, name)
+}
+
+func (i *instrumentedRepository) ListImageHashes(ctx context.Context) ([]ItemImageHash, error) {
+	defer i.observe("ListImageHashes", time.Now())
+	return i.ItemRepository.ListImageHashes(ctx)
+}
+
+// metricVec is a counter or histogram with labels.
+type metricVec struct {
+	name       string
+	help       string
+	kind       string // "counter" or "histogram"
+	labelNames []string
+	buckets    []float64 // upper bounds of a histogram
+
+	mu     sync.Mutex
+	series map[string]*series // keyed by the joined label values
+}
+
+type series struct {
+	labelValues []string
+	value       float64  // counter value
+	counts      []uint64 // histogram counts per bucket, not cumulative
+	sum         float64
+	count       uint64
+}
+
+func newMetricVec(name, help, kind string, buckets []float64, labelNames ...string) *metricVec {
+	return &metricVec{
+		name:       name,
+		help:       help,
+		kind:       kind,
+		labelNames: labelNames,
+		buckets:    buckets,
+		series:     map[string]*series{},
+	}
+}
+
+// get returns the series for the label values. The caller must hold v.mu.
+func (v *metricVec) get(labelValues []string) *series {
+	key := strings.Join(labelValues, "\xff")
+	s, ok := v.series[key]
+	if !ok {
+		s = &series{labelValues: labelValues, counts: make([]uint64, len(v.buckets))}
+		v.series[key] = s
+	}
+	return s
+}
+
+// add adds delta to a counter.
+func (v *metricVec) add(delta float64, labelValues ...string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.get(labelValues).value += delta
+}
+
+// observe adds a sample to a histogram.
+func (v *metricVec) observe(x float64, labelValues ...string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	s := v.get(labelValues)
+	if i := sort.SearchFloat64s(v.buckets, x); i < len(v.buckets) {
+		s.counts[i]++
+	}
+	s.sum += x
+	s.count++
+}
+
+// write writes all series in the text format, sorted by labels for stable output.
+func (v *metricVec) write(w io.Writer) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", v.name, v.help, v.name, v.kind)
+
+	keys := make([]string, 0, len(v.series))
+	for k := range v.series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		s := v.series[k]
+		labels := formatLabels(v.labelNames, s.labelValues)
+		if v.kind != "histogram" {
+			fmt.Fprintf(w, "%s%s %s\n", v.name, labels, formatFloat(s.value))
+			continue
+		}
+
+		var cumulative uint64
+		for i, upper := range v.buckets {
+			cumulative += s.counts[i]
+			le := formatLabels(append(v.labelNames, "le"), append(s.labelValues, formatFloat(upper)))
+			fmt.Fprintf(w, "%s_bucket%s %d\n", v.name, le, cumulative)
+		}
+		le := formatLabels(append(v.labelNames, "le"), append(s.labelValues, "+Inf"))
+		fmt.Fprintf(w, "%s_bucket%s %d\n", v.name, le, s.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", v.name, labels, formatFloat(s.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", v.name, labels, s.count)
+	}
+}
+
+// writeSingle writes a metric without labels.
+func writeSingle(w io.Writer, name, help, kind string, value float64) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %s\n", name, help, name, kind, name, formatFloat(value))
+}
+
+// formatLabels formats label pairs like {method="GET",route="/items"}.
+func formatLabels(names, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = name + `="` + escapeLabelValue(values[i]) + `"`
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// escapeLabelValue escapes a label value as required by the text format.
+func escapeLabelValue(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
+
+func formatFloat(f float64) string {
+	if math.IsInf(f, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// countingWriter counts the bytes written and keeps the first error.
+type countingWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+func (cw *countingWriter) Write(b []byte) (int, error) {
+	if cw.err != nil {
+		return 0, cw.err
+	}
+	n, err := cw.w.Write(b)
+	cw.n += int64(n)
+	cw.err = err
+	return n, err
+}
@@ -0,0 +1,165 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/mock/gomock"
+)
+
+func TestMetricsMiddleware(t *testing.T) {
+	t.Parallel()
+
+	m := NewMetrics(func() sql.DBStats { return sql.DBStats{MaxOpenConnections: 20, OpenConnections: 3} })
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /items/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	mux.HandleFunc("GET /metrics", m.ServeHTTP)
+	h := metricsMiddleware(mux, m)
+
+	for _, path := range []string{"/items/1", "/items/2", "/unknown"} {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+	m.addUploadedBytes(1024)
+	m.observeImageCache(true)
+	m.observeImageCache(false)
+	m.observeImageCache(false)
+	m.observeQuery("List", 3*time.Millisecond)
+
+	res := httptest.NewRecorder()
+	h.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if res.Code != http.StatusOK {
+		t.Fatalf("expected status code %d, got %d", http.StatusOK, res.Code)
+	}
+	if ct := res.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("unexpected Content-Type %q", ct)
+	}
+
+	body := res.Body.String()
+	for _, want := range []string{
+		"# TYPE mercari_http_requests_total counter\n",
+		`mercari_http_requests_total{method="GET",route="/items/{id}",status="404"} 2` + "\n",
+		`mercari_http_requests_total{method="GET",route="unmatched",status="404"} 1` + "\n",
+		"# TYPE mercari_http_request_duration_seconds histogram\n",
+		`mercari_http_request_duration_seconds_bucket{method="GET",route="/items/{id}",le="+Inf"} 2` + "\n",
+		`mercari_http_request_duration_seconds_count{method="GET",route="/items/{id}"} 2` + "\n",
+		// the scrape itself is in flight
+		"mercari_http_requests_in_flight 1\n",
+		"mercari_uploaded_bytes_total 1024\n",
+		"mercari_image_cache_hits_total 1\n",
+		"mercari_image_cache_misses_total 2\n",
+		`mercari_db_query_duration_seconds_bucket{method="List",le="0.0025"} 0` + "\n",
+		`mercari_db_query_duration_seconds_bucket{method="List",le="0.005"} 1` + "\n",
+		`mercari_db_query_duration_seconds_sum{method="List"} 0.003` + "\n",
+		"mercari_db_max_open_connections 20\n",
+		"mercari_db_open_connections 3\n",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected metrics to contain %q, got:\n%s", want, body)
+		}
+	}
+}
+
+func TestInstrumentedRepository(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	mockRepo := NewMockItemRepository(ctrl)
+	mockRepo.EXPECT().Get(gomock.Any(), "1").Return(&Item{ID: 1}, nil)
+
+	m := NewMetrics(nil)
+	repo := instrumentRepository(mockRepo, m)
+	if _, err := repo.Get(context.Background(), "1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var sb strings.Builder
+	if _, err := m.WriteTo(&sb); err != nil {
+		t.Fatalf("failed to write metrics: %v", err)
+	}
+	if want := `mercari_db_query_duration_seconds_count{method="Get"} 1`; !strings.Contains(sb.String(), want) {
+		t.Errorf("expected metrics to contain %q, got:\n%s", want, sb.String())
+	}
+	if strings.Contains(sb.String(), "mercari_db_open_connections") {
+		t.Errorf("expected no pool metrics without stats")
+	}
+}
+
+func TestInstrumentedRepositoryStream(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping e2e test")
+	}
+
+	db, closers, err := setupDB(t)
+	if err != nil {
+		t.Fatalf("failed to set up database: %v", err)
+	}
+	t.Cleanup(func() {
+		for _, c := range closers {
+			c()
+		}
+	})
+
+	ctx := context.Background()
+	m := NewMetrics(nil)
+	repo := instrumentRepository(&itemRepository{db: db}, m)
+	for _, name := range []string{"jacket", "boots"} {
+		if err := repo.Insert(ctx, &Item{Name: name, Category: "fashion", ImageName: "default.jpg"}); err != nil {
+			t.Fatalf("failed to insert item: %v", err)
+		}
+	}
+
+	// a slow client isn't counted as the latency of the database
+	const writeTime = 50 * time.Millisecond
+	err = repo.Stream(ctx, "", "", func(Item) error {
+		time.Sleep(writeTime)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("failed to stream items: %v", err)
+	}
+
+	m.dbQueryDuration.mu.Lock()
+	stream := m.dbQueryDuration.get([]string{"Stream"})
+	count, sum := stream.count, stream.sum
+	m.dbQueryDuration.mu.Unlock()
+	if count != 1 {
+		t.Errorf("expected a query for the single page, got %d", count)
+	}
+	if sum >= writeTime.Seconds() {
+		t.Errorf("expected the time of the query only, got %fs", sum)
+	}
+}
+
+func TestEtagMatches(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		ifNoneMatch string
+		want        bool
+	}{
+		"ok: exact":    {ifNoneMatch: `"abc"`, want: true},
+		"ok: weak":     {ifNoneMatch: `W/"abc"`, want: true},
+		"ok: list":     {ifNoneMatch: `"xyz", "abc"`, want: true},
+		"ok: wildcard": {ifNoneMatch: `*`, want: true},
+		"ng: empty":    {ifNoneMatch: ``, want: false},
+		"ng: other":    {ifNoneMatch: `"xyz"`, want: false},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := etagMatches(tt.ifNoneMatch, `"abc"`); got != tt.want {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
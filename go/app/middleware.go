@@ -1,32 +1,146 @@
 package app
 
 import (
+	"fmt"
 	"log/slog"
 	"net/http"
-	"strings"
+	"runtime/debug"
+	"time"
 )
 
 // This file provides some utility functions for middleware.
-// You do not have to modify this file.
 
-func simpleCORSMiddleware(next http.Handler, origin string, methods []string) http.Handler {
+// requestIDMiddleware propagates the X-Request-ID header of the request, or generates a new one,
+// and stores it in the context so that logs of the request can be correlated.
+// The ID is also returned in the response for clients to report.
+func requestIDMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", origin)
-		w.Header().Set("Access-Control-Allow-Methods", strings.Join(methods, ","))
-		w.Header().Set("Access-Control-Allow-Headers", "*")
+		id := r.Header.Get(requestIDHeader)
+		if !validRequestID.MatchString(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(requestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(withRequestID(r.Context(), id)))
+	})
+}
+
+// accessLogMiddleware logs one line for each completed request with its status code, size and latency.
+// Like metricsMiddleware, it must not replace the request passed to the ServeMux,
+// otherwise the route pattern is unknown here.
+func accessLogMiddleware(next http.Handler, logger *slog.Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rw := newResponseRecorder(w)
+		// deferred so that a request aborted by a panic is logged as well
+		defer func() {
+			level := slog.LevelInfo
+			if rw.status >= http.StatusInternalServerError {
+				level = slog.LevelError
+			}
+			logger.LogAttrs(r.Context(), level, "request completed",
+				slog.String("method", r.Method),
+				slog.String("route", routeLabel(r)),
+				slog.String("path", r.URL.Path),
+				slog.Int("status", rw.status),
+				slog.Int64("bytes", rw.bytes),
+				slog.Float64("duration_ms", float64(time.Since(start).Microseconds())/1000),
+				slog.String("remote_addr", r.RemoteAddr),
+				slog.String("user_agent", r.UserAgent()),
+			)
+		}()
+		next.ServeHTTP(rw, r)
+	})
+}
+
+// responseRecorder wraps an http.ResponseWriter to remember the status code and the response size.
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	bytes       int64
+	wroteHeader bool
+}
+
+func newResponseRecorder(w http.ResponseWriter) *responseRecorder {
+	return &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+}
+
+func (rw *responseRecorder) WriteHeader(code int) {
+	if !rw.wroteHeader {
+		rw.status = code
+		rw.wroteHeader = true
+	}
+	rw.ResponseWriter.WriteHeader(code)
+}
 
-		if r.Method == "OPTIONS" {
-			w.WriteHeader(http.StatusOK)
+func (rw *responseRecorder) Write(b []byte) (int, error) {
+	rw.wroteHeader = true
+	n, err := rw.ResponseWriter.Write(b)
+	rw.bytes += int64(n)
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer, e.g. to flush.
+func (rw *responseRecorder) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+// recoverMiddleware recovers from panics in handlers, so that a bug doesn't kill the connection
+// without a trace. It logs the panic with the stack and returns 500 with a JSON body.
+// If the response has already started, e.g. while http.ServeFile streams an image, the status can't be
+// changed anymore, so the connection is aborted instead to let the client tell the response is broken.
+func recoverMiddleware(next http.Handler, logger *slog.Logger, metrics *Metrics) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rw := newResponseRecorder(w)
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+			// http.ErrAbortHandler is the way to abort a response on purpose
+			if v == http.ErrAbortHandler {
+				panic(v)
+			}
+
+			metrics.observePanic()
+			logger.LogAttrs(r.Context(), slog.LevelError, "panic recovered",
+				slog.String("method", r.Method),
+				slog.String("path", r.URL.Path),
+				slog.Any("panic", v),
+				slog.String("stack", string(debug.Stack())),
+			)
+
+			if rw.wroteHeader {
+				panic(http.ErrAbortHandler)
+			}
+			writeError(w, r, fmt.Errorf("panic: %v", v))
+		}()
+		next.ServeHTTP(rw, r)
+	})
+}
+
+// maxBytesMiddleware limits the size of the request body to limit bytes.
+// Requests declaring a larger Content-Length are rejected with 413 right away,
+// and handlers get an *http.MaxBytesError when they read past the limit.
+func maxBytesMiddleware(next http.Handler, limit int64) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ContentLength > limit {
+			writeError(w, r, &http.MaxBytesError{Limit: limit})
 			return
 		}
-
+		r.Body = http.MaxBytesReader(w, r.Body, limit)
 		next.ServeHTTP(w, r)
 	})
 }
 
-func simpleLoggerMiddleware(next http.Handler) http.Handler {
+// deprecatedMiddleware tells clients that the route is deprecated in favor of the same path under successor.
+// The Deprecation (RFC 9745) and Sunset (RFC 8594) headers tell since and until when it is served,
+// and the Link header points to the replacement.
+func deprecatedMiddleware(next http.Handler, successor string, deprecation, sunset time.Time) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		slog.Info("request received", "method", r.Method, "path", r.URL.Path, "remote_addr", r.RemoteAddr, "user_agent", r.UserAgent())
+		h := w.Header()
+		h.Set("Deprecation", fmt.Sprintf("@%d", deprecation.Unix()))
+		h.Set("Sunset", sunset.UTC().Format(http.TimeFormat))
+		h.Add("Link", fmt.Sprintf(`<%s%s>; rel="successor-version"`, successor, r.URL.EscapedPath()))
 		next.ServeHTTP(w, r)
 	})
 }
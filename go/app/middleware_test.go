@@ -0,0 +1,293 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/mock/gomock"
+)
+
+func TestMaxBytesMiddleware(t *testing.T) {
+	t.Parallel()
+
+	imageBytes, err := os.ReadFile("../images/default.jpg")
+	if err != nil {
+		t.Fatalf("failed to read image file: %v", err)
+	}
+
+	// prepare a multipart body whose image is within the image limit
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	mw.WriteField("name", "jacket")
+	mw.WriteField("category", "fashion")
+	fw, err := mw.CreateFormFile("image", "default.jpg")
+	if err != nil {
+		t.Fatalf("failed to create form file: %v", err)
+	}
+	fw.Write(imageBytes)
+	mw.Close()
+
+	cases := map[string]struct {
+		limit         int64
+		contentLength bool // whether the request declares Content-Length
+		wantCode      int
+	}{
+		"ng: declared length exceeds the limit": {
+			limit:         int64(body.Len()) - 1,
+			contentLength: true,
+			wantCode:      http.StatusRequestEntityTooLarge,
+		},
+		"ng: streamed body exceeds the limit": {
+			limit:         int64(body.Len()) - 1,
+			contentLength: false,
+			wantCode:      http.StatusRequestEntityTooLarge,
+		},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ctrl := gomock.NewController(t)
+			h := &Handlers{imgDirPath: "../images", itemRepo: NewMockItemRepository(ctrl)}
+
+			var reqBody io.Reader = bytes.NewReader(body.Bytes())
+			if !tt.contentLength {
+				// hide the length so that the body is only checked while it is read
+				reqBody = io.MultiReader(reqBody)
+			}
+			req := httptest.NewRequest("POST", "/items", reqBody)
+			if !tt.contentLength {
+				req.ContentLength = -1
+			}
+			req.Header.Set("Content-Type", mw.FormDataContentType())
+			rr := httptest.NewRecorder()
+			maxBytesMiddleware(http.HandlerFunc(h.AddItem), tt.limit).ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantCode {
+				t.Errorf("expected status code %d, got %d", tt.wantCode, rr.Code)
+			}
+			if !strings.Contains(rr.Body.String(), "request body exceeds the maximum size") {
+				t.Errorf("unexpected response body: %s", rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestRequestIDMiddleware(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		header   string
+		generate bool // whether a new ID is expected
+	}{
+		"ok: propagated":                  {header: "abc-123", generate: false},
+		"ok: generated":                   {header: "", generate: true},
+		"ng: invalid header is replaced":  {header: "abc\nlevel=ERROR", generate: true},
+		"ng: too long header is replaced": {header: strings.Repeat("a", 129), generate: true},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var got string
+			h := requestIDMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = requestIDFromContext(r.Context())
+			}))
+
+			req := httptest.NewRequest("GET", "/", nil)
+			if tt.header != "" {
+				req.Header.Set(requestIDHeader, tt.header)
+			}
+			rr := httptest.NewRecorder()
+			h.ServeHTTP(rr, req)
+
+			if got == "" {
+				t.Fatalf("expected a request ID in the context")
+			}
+			if rr.Header().Get(requestIDHeader) != got {
+				t.Errorf("expected response header %q, got %q", got, rr.Header().Get(requestIDHeader))
+			}
+			if propagated := got == tt.header; propagated == tt.generate {
+				t.Errorf("expected generated=%v, got ID %q for header %q", tt.generate, got, tt.header)
+			}
+		})
+	}
+}
+
+func TestAccessLogMiddleware(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	logger := slog.New(contextHandler{slog.NewJSONHandler(&buf, nil)})
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /items/{id}", func(w http.ResponseWriter, r *http.Request) {
+		logger.InfoContext(r.Context(), "in handler")
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("not found"))
+	})
+	h := requestIDMiddleware(accessLogMiddleware(mux, logger))
+
+	req := httptest.NewRequest("GET", "/items/42", nil)
+	req.Header.Set(requestIDHeader, "req-1")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 log lines, got %d: %s", len(lines), buf.String())
+	}
+
+	var handlerLog map[string]any
+	if err := json.Unmarshal([]byte(lines[0]), &handlerLog); err != nil {
+		t.Fatalf("failed to parse log line: %v", err)
+	}
+	if handlerLog["request_id"] != "req-1" {
+		t.Errorf("expected request_id in handler log, got %v", handlerLog)
+	}
+
+	var accessLog map[string]any
+	if err := json.Unmarshal([]byte(lines[1]), &accessLog); err != nil {
+		t.Fatalf("failed to parse log line: %v", err)
+	}
+	want := map[string]any{
+		"msg":        "request completed",
+		"request_id": "req-1",
+		"method":     "GET",
+		"route":      "/items/{id}",
+		"path":       "/items/42",
+		"status":     float64(http.StatusNotFound),
+		"bytes":      float64(len("not found")),
+	}
+	for k, v := range want {
+		if accessLog[k] != v {
+			t.Errorf("expected %s=%v, got %v", k, v, accessLog[k])
+		}
+	}
+	if _, ok := accessLog["duration_ms"]; !ok {
+		t.Errorf("expected duration_ms in access log, got %v", accessLog)
+	}
+}
+
+func TestRecoverMiddleware(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		handler   http.HandlerFunc
+		wantCode  int
+		wantAbort bool // whether the connection is expected to be aborted
+	}{
+		"ok: no panic": {
+			handler:  func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("ok")) },
+			wantCode: http.StatusOK,
+		},
+		"ng: panic before the response": {
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				var item *Item
+				_ = item.Name
+			},
+			wantCode: http.StatusInternalServerError,
+		},
+		"ng: panic while streaming": {
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("partial"))
+				panic("broken stream")
+			},
+			wantCode:  http.StatusOK,
+			wantAbort: true,
+		},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var buf bytes.Buffer
+			logger := slog.New(contextHandler{slog.NewJSONHandler(&buf, nil)})
+			m := NewMetrics(nil)
+			h := requestIDMiddleware(recoverMiddleware(tt.handler, logger, m))
+
+			req := httptest.NewRequest("GET", "/", nil)
+			req.Header.Set(requestIDHeader, "req-1")
+			rr := httptest.NewRecorder()
+
+			aborted := func() (aborted bool) {
+				defer func() {
+					if v := recover(); v != nil {
+						if v != http.ErrAbortHandler {
+							t.Fatalf("unexpected panic: %v", v)
+						}
+						aborted = true
+					}
+				}()
+				h.ServeHTTP(rr, req)
+				return false
+			}()
+
+			if aborted != tt.wantAbort {
+				t.Errorf("expected aborted=%v, got %v", tt.wantAbort, aborted)
+			}
+			if rr.Code != tt.wantCode {
+				t.Errorf("expected status code %d, got %d", tt.wantCode, rr.Code)
+			}
+			if tt.wantCode == http.StatusInternalServerError {
+				var body ErrorResponse
+				if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+					t.Fatalf("failed to parse response body: %v", err)
+				}
+				if body.Error.Code != codeInternal || body.Error.RequestID != "req-1" {
+					t.Errorf("unexpected error response: %+v", body.Error)
+				}
+				if strings.Contains(body.Error.Message, "nil pointer") {
+					t.Errorf("expected the panic not to be exposed, got %q", body.Error.Message)
+				}
+			}
+
+			panicked := tt.wantCode == http.StatusInternalServerError || tt.wantAbort
+			var wantPanics int64
+			if panicked {
+				wantPanics = 1
+			}
+			if got := m.panics.Load(); got != wantPanics {
+				t.Errorf("expected %d panics to be counted, got %d", wantPanics, got)
+			}
+			if logged := strings.Contains(buf.String(), `"stack":`) && strings.Contains(buf.String(), `"request_id":"req-1"`); logged != panicked {
+				t.Errorf("expected logged=%v, got log: %s", panicked, buf.String())
+			}
+		})
+	}
+}
+
+func TestDeprecatedMiddleware(t *testing.T) {
+	t.Parallel()
+
+	deprecation := time.Date(2026, time.October, 18, 0, 0, 0, 0, time.UTC)
+	sunset := time.Date(2027, time.April, 1, 0, 0, 0, 0, time.UTC)
+	h := deprecatedMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}), "/v1", deprecation, sunset)
+
+	res := httptest.NewRecorder()
+	h.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/items/1/similar-images?max_distance=5", nil))
+
+	want := map[string]string{
+		"Deprecation": "@1792281600",
+		"Sunset":      "Thu, 01 Apr 2027 00:00:00 GMT",
+		"Link":        `</v1/items/1/similar-images>; rel="successor-version"`,
+	}
+	for name, value := range want {
+		if got := res.Header().Get(name); got != value {
+			t.Errorf("expected %s %q, got %q", name, value, got)
+		}
+	}
+}
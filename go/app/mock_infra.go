@@ -11,6 +11,7 @@ package app
 
 import (
 	context "context"
+	sql "database/sql"
 	reflect "reflect"
 
 	gomock "go.uber.org/mock/gomock"
@@ -99,6 +100,21 @@ func (mr *MockItemRepositoryMockRecorder) GetCategoryName(ctx, categoryID any) *
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCategoryName", reflect.TypeOf((*MockItemRepository)(nil).GetCategoryName), ctx, categoryID)
 }
 
+// GetImageInfo mocks base method.
+func (m *MockItemRepository) GetImageInfo(ctx context.Context, name string) (*ImageInfo, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetImageInfo", ctx, name)
+	ret0, _ := ret[0].(*ImageInfo)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetImageInfo indicates an expected call of GetImageInfo.
+func (mr *MockItemRepositoryMockRecorder) GetImageInfo(ctx, name any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetImageInfo", reflect.TypeOf((*MockItemRepository)(nil).GetImageInfo), ctx, name)
+}
+
 // Insert mocks base method.
 func (m *MockItemRepository) Insert(ctx context.Context, item *Item) error {
 	m.ctrl.T.Helper()
@@ -113,6 +129,20 @@ func (mr *MockItemRepositoryMockRecorder) Insert(ctx, item any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Insert", reflect.TypeOf((*MockItemRepository)(nil).Insert), ctx, item)
 }
 
+// InsertBatch mocks base method.
+func (m *MockItemRepository) InsertBatch(ctx context.Context, items []*Item) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "InsertBatch", ctx, items)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// InsertBatch indicates an expected call of InsertBatch.
+func (mr *MockItemRepositoryMockRecorder) InsertBatch(ctx, items any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InsertBatch", reflect.TypeOf((*MockItemRepository)(nil).InsertBatch), ctx, items)
+}
+
 // List mocks base method.
 func (m *MockItemRepository) List(ctx context.Context) ([]Item, error) {
 	m.ctrl.T.Helper()
@@ -128,6 +158,64 @@ func (mr *MockItemRepositoryMockRecorder) List(ctx any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*MockItemRepository)(nil).List), ctx)
 }
 
+// ListByCategory mocks base method.
+func (m *MockItemRepository) ListByCategory(ctx context.Context, category string) ([]Item, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListByCategory", ctx, category)
+	ret0, _ := ret[0].([]Item)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListByCategory indicates an expected call of ListByCategory.
+func (mr *MockItemRepositoryMockRecorder) ListByCategory(ctx, category any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListByCategory", reflect.TypeOf((*MockItemRepository)(nil).ListByCategory), ctx, category)
+}
+
+// ListImageHashes mocks base method.
+func (m *MockItemRepository) ListImageHashes(ctx context.Context) ([]ItemImageHash, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListImageHashes", ctx)
+	ret0, _ := ret[0].([]ItemImageHash)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListImageHashes indicates an expected call of ListImageHashes.
+func (mr *MockItemRepositoryMockRecorder) ListImageHashes(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListImageHashes", reflect.TypeOf((*MockItemRepository)(nil).ListImageHashes), ctx)
+}
+
+// Ping mocks base method.
+func (m *MockItemRepository) Ping(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Ping", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Ping indicates an expected call of Ping.
+func (mr *MockItemRepositoryMockRecorder) Ping(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Ping", reflect.TypeOf((*MockItemRepository)(nil).Ping), ctx)
+}
+
+// SaveImage mocks base method.
+func (m *MockItemRepository) SaveImage(ctx context.Context, info *ImageInfo) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveImage", ctx, info)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveImage indicates an expected call of SaveImage.
+func (mr *MockItemRepositoryMockRecorder) SaveImage(ctx, info any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveImage", reflect.TypeOf((*MockItemRepository)(nil).SaveImage), ctx, info)
+}
+
 // Search mocks base method.
 func (m *MockItemRepository) Search(ctx context.Context, keyword string) ([]Item, error) {
 	m.ctrl.T.Helper()
@@ -142,3 +230,695 @@ func (mr *MockItemRepositoryMockRecorder) Search(ctx, keyword any) *gomock.Call
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Search", reflect.TypeOf((*MockItemRepository)(nil).Search), ctx, keyword)
 }
+
+// Stream mocks base method.
+func (m *MockItemRepository) Stream(ctx context.Context, keyword, category string, fn func(Item) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Stream", ctx, keyword, category, fn)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Stream indicates an expected call of Stream.
+func (mr *MockItemRepositoryMockRecorder) Stream(ctx, keyword, category, fn any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Stream", reflect.TypeOf((*MockItemRepository)(nil).Stream), ctx, keyword, category, fn)
+}
+
+// MockLegacyRepository is a mock of LegacyRepository interface.
+type MockLegacyRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockLegacyRepositoryMockRecorder
+	isgomock struct{}
+}
+
+// MockLegacyRepositoryMockRecorder is the mock recorder for MockLegacyRepository.
+type MockLegacyRepositoryMockRecorder struct {
+	mock *MockLegacyRepository
+}
+
+// NewMockLegacyRepository creates a new mock instance.
+func NewMockLegacyRepository(ctrl *gomock.Controller) *MockLegacyRepository {
+	mock := &MockLegacyRepository{ctrl: ctrl}
+	mock.recorder = &MockLegacyRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockLegacyRepository) EXPECT() *MockLegacyRepositoryMockRecorder {
+	return m.recorder
+}
+
+// Close mocks base method.
+func (m *MockLegacyRepository) Close() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Close")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Close indicates an expected call of Close.
+func (mr *MockLegacyRepositoryMockRecorder) Close() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Close", reflect.TypeOf((*MockLegacyRepository)(nil).Close))
+}
+
+// Get mocks base method.
+func (m *MockLegacyRepository) Get(ctx context.Context, id string) (*Item, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Get", ctx, id)
+	ret0, _ := ret[0].(*Item)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Get indicates an expected call of Get.
+func (mr *MockLegacyRepositoryMockRecorder) Get(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockLegacyRepository)(nil).Get), ctx, id)
+}
+
+// GetCategoryID mocks base method.
+func (m *MockLegacyRepository) GetCategoryID(ctx context.Context, categoryName string) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetCategoryID", ctx, categoryName)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetCategoryID indicates an expected call of GetCategoryID.
+func (mr *MockLegacyRepositoryMockRecorder) GetCategoryID(ctx, categoryName any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCategoryID", reflect.TypeOf((*MockLegacyRepository)(nil).GetCategoryID), ctx, categoryName)
+}
+
+// GetCategoryName mocks base method.
+func (m *MockLegacyRepository) GetCategoryName(ctx context.Context, categoryID int) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetCategoryName", ctx, categoryID)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetCategoryName indicates an expected call of GetCategoryName.
+func (mr *MockLegacyRepositoryMockRecorder) GetCategoryName(ctx, categoryID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCategoryName", reflect.TypeOf((*MockLegacyRepository)(nil).GetCategoryName), ctx, categoryID)
+}
+
+// GetImageInfo mocks base method.
+func (m *MockLegacyRepository) GetImageInfo(ctx context.Context, name string) (*ImageInfo, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetImageInfo", ctx, name)
+	ret0, _ := ret[0].(*ImageInfo)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetImageInfo indicates an expected call of GetImageInfo.
+func (mr *MockLegacyRepositoryMockRecorder) GetImageInfo(ctx, name any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetImageInfo", reflect.TypeOf((*MockLegacyRepository)(nil).GetImageInfo), ctx, name)
+}
+
+// GetLegacyItemID mocks base method.
+func (m *MockLegacyRepository) GetLegacyItemID(ctx context.Context, key string) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLegacyItemID", ctx, key)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLegacyItemID indicates an expected call of GetLegacyItemID.
+func (mr *MockLegacyRepositoryMockRecorder) GetLegacyItemID(ctx, key any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLegacyItemID", reflect.TypeOf((*MockLegacyRepository)(nil).GetLegacyItemID), ctx, key)
+}
+
+// Insert mocks base method.
+func (m *MockLegacyRepository) Insert(ctx context.Context, item *Item) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Insert", ctx, item)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Insert indicates an expected call of Insert.
+func (mr *MockLegacyRepositoryMockRecorder) Insert(ctx, item any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Insert", reflect.TypeOf((*MockLegacyRepository)(nil).Insert), ctx, item)
+}
+
+// InsertBatch mocks base method.
+func (m *MockLegacyRepository) InsertBatch(ctx context.Context, items []*Item) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "InsertBatch", ctx, items)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// InsertBatch indicates an expected call of InsertBatch.
+func (mr *MockLegacyRepositoryMockRecorder) InsertBatch(ctx, items any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InsertBatch", reflect.TypeOf((*MockLegacyRepository)(nil).InsertBatch), ctx, items)
+}
+
+// InsertLegacyItem mocks base method.
+func (m *MockLegacyRepository) InsertLegacyItem(ctx context.Context, key string, item *Item) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "InsertLegacyItem", ctx, key, item)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// InsertLegacyItem indicates an expected call of InsertLegacyItem.
+func (mr *MockLegacyRepositoryMockRecorder) InsertLegacyItem(ctx, key, item any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InsertLegacyItem", reflect.TypeOf((*MockLegacyRepository)(nil).InsertLegacyItem), ctx, key, item)
+}
+
+// List mocks base method.
+func (m *MockLegacyRepository) List(ctx context.Context) ([]Item, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "List", ctx)
+	ret0, _ := ret[0].([]Item)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// List indicates an expected call of List.
+func (mr *MockLegacyRepositoryMockRecorder) List(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*MockLegacyRepository)(nil).List), ctx)
+}
+
+// ListByCategory mocks base method.
+func (m *MockLegacyRepository) ListByCategory(ctx context.Context, category string) ([]Item, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListByCategory", ctx, category)
+	ret0, _ := ret[0].([]Item)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListByCategory indicates an expected call of ListByCategory.
+func (mr *MockLegacyRepositoryMockRecorder) ListByCategory(ctx, category any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListByCategory", reflect.TypeOf((*MockLegacyRepository)(nil).ListByCategory), ctx, category)
+}
+
+// ListImageHashes mocks base method.
+func (m *MockLegacyRepository) ListImageHashes(ctx context.Context) ([]ItemImageHash, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListImageHashes", ctx)
+	ret0, _ := ret[0].([]ItemImageHash)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListImageHashes indicates an expected call of ListImageHashes.
+func (mr *MockLegacyRepositoryMockRecorder) ListImageHashes(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListImageHashes", reflect.TypeOf((*MockLegacyRepository)(nil).ListImageHashes), ctx)
+}
+
+// Ping mocks base method.
+func (m *MockLegacyRepository) Ping(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Ping", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Ping indicates an expected call of Ping.
+func (mr *MockLegacyRepositoryMockRecorder) Ping(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Ping", reflect.TypeOf((*MockLegacyRepository)(nil).Ping), ctx)
+}
+
+// SaveImage mocks base method.
+func (m *MockLegacyRepository) SaveImage(ctx context.Context, info *ImageInfo) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveImage", ctx, info)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveImage indicates an expected call of SaveImage.
+func (mr *MockLegacyRepositoryMockRecorder) SaveImage(ctx, info any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveImage", reflect.TypeOf((*MockLegacyRepository)(nil).SaveImage), ctx, info)
+}
+
+// Search mocks base method.
+func (m *MockLegacyRepository) Search(ctx context.Context, keyword string) ([]Item, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Search", ctx, keyword)
+	ret0, _ := ret[0].([]Item)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Search indicates an expected call of Search.
+func (mr *MockLegacyRepositoryMockRecorder) Search(ctx, keyword any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Search", reflect.TypeOf((*MockLegacyRepository)(nil).Search), ctx, keyword)
+}
+
+// Stream mocks base method.
+func (m *MockLegacyRepository) Stream(ctx context.Context, keyword, category string, fn func(Item) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Stream", ctx, keyword, category, fn)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Stream indicates an expected call of Stream.
+func (mr *MockLegacyRepositoryMockRecorder) Stream(ctx, keyword, category, fn any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Stream", reflect.TypeOf((*MockLegacyRepository)(nil).Stream), ctx, keyword, category, fn)
+}
+
+// MockAdminRepository is a mock of AdminRepository interface.
+type MockAdminRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockAdminRepositoryMockRecorder
+	isgomock struct{}
+}
+
+// MockAdminRepositoryMockRecorder is the mock recorder for MockAdminRepository.
+type MockAdminRepositoryMockRecorder struct {
+	mock *MockAdminRepository
+}
+
+// NewMockAdminRepository creates a new mock instance.
+func NewMockAdminRepository(ctrl *gomock.Controller) *MockAdminRepository {
+	mock := &MockAdminRepository{ctrl: ctrl}
+	mock.recorder = &MockAdminRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockAdminRepository) EXPECT() *MockAdminRepositoryMockRecorder {
+	return m.recorder
+}
+
+// Backup mocks base method.
+func (m *MockAdminRepository) Backup(ctx context.Context, path string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Backup", ctx, path)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Backup indicates an expected call of Backup.
+func (mr *MockAdminRepositoryMockRecorder) Backup(ctx, path any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Backup", reflect.TypeOf((*MockAdminRepository)(nil).Backup), ctx, path)
+}
+
+// CatalogStats mocks base method.
+func (m *MockAdminRepository) CatalogStats(ctx context.Context) (*CatalogStats, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CatalogStats", ctx)
+	ret0, _ := ret[0].(*CatalogStats)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CatalogStats indicates an expected call of CatalogStats.
+func (mr *MockAdminRepositoryMockRecorder) CatalogStats(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CatalogStats", reflect.TypeOf((*MockAdminRepository)(nil).CatalogStats), ctx)
+}
+
+// Close mocks base method.
+func (m *MockAdminRepository) Close() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Close")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Close indicates an expected call of Close.
+func (mr *MockAdminRepositoryMockRecorder) Close() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Close", reflect.TypeOf((*MockAdminRepository)(nil).Close))
+}
+
+// Delete mocks base method.
+func (m *MockAdminRepository) Delete(ctx context.Context, id int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Delete", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Delete indicates an expected call of Delete.
+func (mr *MockAdminRepositoryMockRecorder) Delete(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockAdminRepository)(nil).Delete), ctx, id)
+}
+
+// DeleteCategory mocks base method.
+func (m *MockAdminRepository) DeleteCategory(ctx context.Context, name string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteCategory", ctx, name)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteCategory indicates an expected call of DeleteCategory.
+func (mr *MockAdminRepositoryMockRecorder) DeleteCategory(ctx, name any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteCategory", reflect.TypeOf((*MockAdminRepository)(nil).DeleteCategory), ctx, name)
+}
+
+// Get mocks base method.
+func (m *MockAdminRepository) Get(ctx context.Context, id string) (*Item, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Get", ctx, id)
+	ret0, _ := ret[0].(*Item)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Get indicates an expected call of Get.
+func (mr *MockAdminRepositoryMockRecorder) Get(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockAdminRepository)(nil).Get), ctx, id)
+}
+
+// GetCategoryID mocks base method.
+func (m *MockAdminRepository) GetCategoryID(ctx context.Context, categoryName string) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetCategoryID", ctx, categoryName)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetCategoryID indicates an expected call of GetCategoryID.
+func (mr *MockAdminRepositoryMockRecorder) GetCategoryID(ctx, categoryName any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCategoryID", reflect.TypeOf((*MockAdminRepository)(nil).GetCategoryID), ctx, categoryName)
+}
+
+// GetCategoryName mocks base method.
+func (m *MockAdminRepository) GetCategoryName(ctx context.Context, categoryID int) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetCategoryName", ctx, categoryID)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetCategoryName indicates an expected call of GetCategoryName.
+func (mr *MockAdminRepositoryMockRecorder) GetCategoryName(ctx, categoryID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCategoryName", reflect.TypeOf((*MockAdminRepository)(nil).GetCategoryName), ctx, categoryID)
+}
+
+// GetImageInfo mocks base method.
+func (m *MockAdminRepository) GetImageInfo(ctx context.Context, name string) (*ImageInfo, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetImageInfo", ctx, name)
+	ret0, _ := ret[0].(*ImageInfo)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetImageInfo indicates an expected call of GetImageInfo.
+func (mr *MockAdminRepositoryMockRecorder) GetImageInfo(ctx, name any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetImageInfo", reflect.TypeOf((*MockAdminRepository)(nil).GetImageInfo), ctx, name)
+}
+
+// Insert mocks base method.
+func (m *MockAdminRepository) Insert(ctx context.Context, item *Item) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Insert", ctx, item)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Insert indicates an expected call of Insert.
+func (mr *MockAdminRepositoryMockRecorder) Insert(ctx, item any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Insert", reflect.TypeOf((*MockAdminRepository)(nil).Insert), ctx, item)
+}
+
+// InsertBatch mocks base method.
+func (m *MockAdminRepository) InsertBatch(ctx context.Context, items []*Item) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "InsertBatch", ctx, items)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// InsertBatch indicates an expected call of InsertBatch.
+func (mr *MockAdminRepositoryMockRecorder) InsertBatch(ctx, items any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InsertBatch", reflect.TypeOf((*MockAdminRepository)(nil).InsertBatch), ctx, items)
+}
+
+// List mocks base method.
+func (m *MockAdminRepository) List(ctx context.Context) ([]Item, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "List", ctx)
+	ret0, _ := ret[0].([]Item)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// List indicates an expected call of List.
+func (mr *MockAdminRepositoryMockRecorder) List(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*MockAdminRepository)(nil).List), ctx)
+}
+
+// ListByCategory mocks base method.
+func (m *MockAdminRepository) ListByCategory(ctx context.Context, category string) ([]Item, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListByCategory", ctx, category)
+	ret0, _ := ret[0].([]Item)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListByCategory indicates an expected call of ListByCategory.
+func (mr *MockAdminRepositoryMockRecorder) ListByCategory(ctx, category any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListByCategory", reflect.TypeOf((*MockAdminRepository)(nil).ListByCategory), ctx, category)
+}
+
+// ListCategories mocks base method.
+func (m *MockAdminRepository) ListCategories(ctx context.Context) ([]Category, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListCategories", ctx)
+	ret0, _ := ret[0].([]Category)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListCategories indicates an expected call of ListCategories.
+func (mr *MockAdminRepositoryMockRecorder) ListCategories(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListCategories", reflect.TypeOf((*MockAdminRepository)(nil).ListCategories), ctx)
+}
+
+// ListImageHashes mocks base method.
+func (m *MockAdminRepository) ListImageHashes(ctx context.Context) ([]ItemImageHash, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListImageHashes", ctx)
+	ret0, _ := ret[0].([]ItemImageHash)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListImageHashes indicates an expected call of ListImageHashes.
+func (mr *MockAdminRepositoryMockRecorder) ListImageHashes(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListImageHashes", reflect.TypeOf((*MockAdminRepository)(nil).ListImageHashes), ctx)
+}
+
+// Migrate mocks base method.
+func (m *MockAdminRepository) Migrate(ctx context.Context, schemaPath string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Migrate", ctx, schemaPath)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Migrate indicates an expected call of Migrate.
+func (mr *MockAdminRepositoryMockRecorder) Migrate(ctx, schemaPath any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Migrate", reflect.TypeOf((*MockAdminRepository)(nil).Migrate), ctx, schemaPath)
+}
+
+// Ping mocks base method.
+func (m *MockAdminRepository) Ping(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Ping", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Ping indicates an expected call of Ping.
+func (mr *MockAdminRepositoryMockRecorder) Ping(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Ping", reflect.TypeOf((*MockAdminRepository)(nil).Ping), ctx)
+}
+
+// RenameCategory mocks base method.
+func (m *MockAdminRepository) RenameCategory(ctx context.Context, name, newName string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RenameCategory", ctx, name, newName)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RenameCategory indicates an expected call of RenameCategory.
+func (mr *MockAdminRepositoryMockRecorder) RenameCategory(ctx, name, newName any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RenameCategory", reflect.TypeOf((*MockAdminRepository)(nil).RenameCategory), ctx, name, newName)
+}
+
+// SaveImage mocks base method.
+func (m *MockAdminRepository) SaveImage(ctx context.Context, info *ImageInfo) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveImage", ctx, info)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveImage indicates an expected call of SaveImage.
+func (mr *MockAdminRepositoryMockRecorder) SaveImage(ctx, info any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveImage", reflect.TypeOf((*MockAdminRepository)(nil).SaveImage), ctx, info)
+}
+
+// SchemaVersion mocks base method.
+func (m *MockAdminRepository) SchemaVersion(ctx context.Context) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SchemaVersion", ctx)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SchemaVersion indicates an expected call of SchemaVersion.
+func (mr *MockAdminRepositoryMockRecorder) SchemaVersion(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SchemaVersion", reflect.TypeOf((*MockAdminRepository)(nil).SchemaVersion), ctx)
+}
+
+// Search mocks base method.
+func (m *MockAdminRepository) Search(ctx context.Context, keyword string) ([]Item, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Search", ctx, keyword)
+	ret0, _ := ret[0].([]Item)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Search indicates an expected call of Search.
+func (mr *MockAdminRepositoryMockRecorder) Search(ctx, keyword any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Search", reflect.TypeOf((*MockAdminRepository)(nil).Search), ctx, keyword)
+}
+
+// Stream mocks base method.
+func (m *MockAdminRepository) Stream(ctx context.Context, keyword, category string, fn func(Item) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Stream", ctx, keyword, category, fn)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Stream indicates an expected call of Stream.
+func (mr *MockAdminRepositoryMockRecorder) Stream(ctx, keyword, category, fn any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Stream", reflect.TypeOf((*MockAdminRepository)(nil).Stream), ctx, keyword, category, fn)
+}
+
+// Vacuum mocks base method.
+func (m *MockAdminRepository) Vacuum(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Vacuum", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Vacuum indicates an expected call of Vacuum.
+func (mr *MockAdminRepositoryMockRecorder) Vacuum(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Vacuum", reflect.TypeOf((*MockAdminRepository)(nil).Vacuum), ctx)
+}
+
+// MockdbExecutor is a mock of dbExecutor interface.
+type MockdbExecutor struct {
+	ctrl     *gomock.Controller
+	recorder *MockdbExecutorMockRecorder
+	isgomock struct{}
+}
+
+// MockdbExecutorMockRecorder is the mock recorder for MockdbExecutor.
+type MockdbExecutorMockRecorder struct {
+	mock *MockdbExecutor
+}
+
+// NewMockdbExecutor creates a new mock instance.
+func NewMockdbExecutor(ctrl *gomock.Controller) *MockdbExecutor {
+	mock := &MockdbExecutor{ctrl: ctrl}
+	mock.recorder = &MockdbExecutorMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockdbExecutor) EXPECT() *MockdbExecutorMockRecorder {
+	return m.recorder
+}
+
+// ExecContext mocks base method.
+func (m *MockdbExecutor) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	m.ctrl.T.Helper()
+	varargs := []any{ctx, query}
+	for _, a := range args {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "ExecContext", varargs...)
+	ret0, _ := ret[0].(sql.Result)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ExecContext indicates an expected call of ExecContext.
+func (mr *MockdbExecutorMockRecorder) ExecContext(ctx, query any, args ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{ctx, query}, args...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExecContext", reflect.TypeOf((*MockdbExecutor)(nil).ExecContext), varargs...)
+}
+
+// QueryRowContext mocks base method.
+func (m *MockdbExecutor) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	m.ctrl.T.Helper()
+	varargs := []any{ctx, query}
+	for _, a := range args {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "QueryRowContext", varargs...)
+	ret0, _ := ret[0].(*sql.Row)
+	return ret0
+}
+
+// QueryRowContext indicates an expected call of QueryRowContext.
+func (mr *MockdbExecutorMockRecorder) QueryRowContext(ctx, query any, args ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{ctx, query}, args...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "QueryRowContext", reflect.TypeOf((*MockdbExecutor)(nil).QueryRowContext), varargs...)
+}
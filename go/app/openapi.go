@@ -0,0 +1,358 @@
+package app
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// openAPIVersion is the version of the API in the spec. Bump it when the API changes.
+const openAPIVersion = "1.0.0"
+
+// openAPIDocument is an OpenAPI 3 document.
+// Only the parts of the specification used by this API are modeled.
+type openAPIDocument struct {
+	OpenAPI    string                                 `json:"openapi"`
+	Info       openAPIInfo                            `json:"info"`
+	Paths      map[string]map[string]openAPIOperation `json:"paths"`
+	Components openAPIComponents                      `json:"components"`
+}
+
+type openAPIInfo struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type openAPIComponents struct {
+	Schemas map[string]*openAPISchema `json:"schemas"`
+}
+
+type openAPIOperation struct {
+	OperationID string                     `json:"operationId"`
+	Summary     string                     `json:"summary"`
+	Parameters  []openAPIParameter         `json:"parameters,omitempty"`
+	RequestBody *openAPIRequestBody        `json:"requestBody,omitempty"`
+	Responses   map[string]openAPIResponse `json:"responses"`
+}
+
+type openAPIParameter struct {
+	Name     string         `json:"name"`
+	In       string         `json:"in"` // "path" or "query"
+	Required bool           `json:"required"`
+	Schema   *openAPISchema `json:"schema"`
+}
+
+type openAPIRequestBody struct {
+	Required bool                        `json:"required"`
+	Content  map[string]openAPIMediaType `json:"content"`
+}
+
+type openAPIResponse struct {
+	Description string                      `json:"description"`
+	Headers     map[string]openAPIHeader    `json:"headers,omitempty"`
+	Content     map[string]openAPIMediaType `json:"content,omitempty"`
+}
+
+type openAPIHeader struct {
+	Description string         `json:"description"`
+	Schema      *openAPISchema `json:"schema"`
+}
+
+type openAPIMediaType struct {
+	Schema *openAPISchema `json:"schema,omitempty"`
+}
+
+type openAPISchema struct {
+	Ref                  string                    `json:"$ref,omitempty"`
+	Type                 string                    `json:"type,omitempty"`
+	Format               string                    `json:"format,omitempty"`
+	Properties           map[string]*openAPISchema `json:"properties,omitempty"`
+	Required             []string                  `json:"required,omitempty"`
+	Items                *openAPISchema            `json:"items,omitempty"`
+	AdditionalProperties *openAPISchema            `json:"additionalProperties,omitempty"`
+	MinLength            *int                      `json:"minLength,omitempty"`
+	MaxLength            *int                      `json:"maxLength,omitempty"`
+}
+
+// newOpenAPISpec documents the routes as an OpenAPI 3 document.
+// The schemas are generated from the Go types of the requests and responses by their json, form and query tags,
+// so changing a response struct changes the spec as well.
+func newOpenAPISpec(rs []route) *openAPIDocument {
+	doc := &openAPIDocument{
+		OpenAPI:    "3.0.3",
+		Info:       openAPIInfo{Title: "Mercari Build Training API", Version: openAPIVersion},
+		Paths:      map[string]map[string]openAPIOperation{},
+		Components: openAPIComponents{Schemas: map[string]*openAPISchema{}},
+	}
+	for _, rt := range rs {
+		if rt.successor != "" {
+			continue
+		}
+		path := openAPIPath(rt.path)
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = map[string]openAPIOperation{}
+		}
+		doc.Paths[path][strings.ToLower(rt.method)] = doc.operation(rt)
+	}
+	return doc
+}
+
+// openAPIPath converts a ServeMux pattern to an OpenAPI path, which always matches exactly.
+func openAPIPath(pattern string) string {
+	return strings.ReplaceAll(strings.TrimSuffix(pattern, "{$}"), "...}", "}")
+}
+
+// operation documents a route as an operation.
+func (doc *openAPIDocument) operation(rt route) openAPIOperation {
+	op := openAPIOperation{
+		OperationID: rt.doc.id,
+		Summary:     rt.doc.summary,
+		Parameters:  append(pathParameters(rt.path), doc.queryParameters(rt.doc.query)...),
+		Responses:   map[string]openAPIResponse{},
+	}
+	if rt.doc.form != nil {
+		op.RequestBody = &openAPIRequestBody{
+			Required: true,
+			Content:  map[string]openAPIMediaType{"multipart/form-data": {Schema: doc.formSchema(rt.doc.form)}},
+		}
+	}
+	if len(rt.doc.bodyTypes) > 0 {
+		op.RequestBody = &openAPIRequestBody{Required: true, Content: map[string]openAPIMediaType{}}
+		for _, contentType := range rt.doc.bodyTypes {
+			op.RequestBody.Content[contentType] = openAPIMediaType{Schema: &openAPISchema{Type: "string"}}
+		}
+	}
+
+	for _, resp := range rt.doc.responses {
+		r := openAPIResponse{Description: resp.description}
+		switch {
+		case resp.body != nil:
+			r.Content = map[string]openAPIMediaType{"application/json": {Schema: doc.schema(reflect.TypeOf(resp.body))}}
+		case resp.contentType != "":
+			r.Content = map[string]openAPIMediaType{resp.contentType: {}}
+		}
+		for _, contentType := range resp.otherTypes {
+			r.Content[contentType] = openAPIMediaType{}
+		}
+		if rt.rateLimit != "" {
+			r.Headers = rateLimitHeaders(false)
+		}
+		op.Responses[strconv.Itoa(resp.status)] = r
+	}
+
+	statuses := slices.Clone(rt.doc.errors)
+	if rt.upload {
+		statuses = append(statuses, http.StatusRequestEntityTooLarge)
+	}
+	if rt.rateLimit != "" {
+		statuses = append(statuses, http.StatusTooManyRequests)
+	}
+	statuses = append(statuses, http.StatusInternalServerError)
+	errorSchema := doc.schema(reflect.TypeOf(ErrorResponse{}))
+	for _, status := range statuses {
+		r := openAPIResponse{
+			Description: http.StatusText(status),
+			Content:     map[string]openAPIMediaType{"application/json": {Schema: errorSchema}},
+		}
+		if rt.rateLimit != "" {
+			r.Headers = rateLimitHeaders(status == http.StatusTooManyRequests)
+		}
+		op.Responses[strconv.Itoa(status)] = r
+	}
+	return op
+}
+
+// rateLimitHeaders documents the headers set by rateLimiter.
+func rateLimitHeaders(limited bool) map[string]openAPIHeader {
+	integer := &openAPISchema{Type: "integer"}
+	headers := map[string]openAPIHeader{
+		"X-RateLimit-Limit":     {Description: "Number of requests the client can make at once", Schema: integer},
+		"X-RateLimit-Remaining": {Description: "Number of requests left", Schema: integer},
+		"X-RateLimit-Reset":     {Description: "Seconds until the budget is fully restored", Schema: integer},
+	}
+	if limited {
+		headers["Retry-After"] = openAPIHeader{Description: "Seconds until the next request is allowed", Schema: integer}
+	}
+	return headers
+}
+
+// pathParameters documents the wildcards of a ServeMux pattern such as "/items/{id}".
+func pathParameters(path string) []openAPIParameter {
+	var params []openAPIParameter
+	for _, segment := range strings.Split(openAPIPath(path), "/") {
+		name, ok := strings.CutPrefix(segment, "{")
+		if !ok {
+			continue
+		}
+		name = strings.TrimSuffix(name, "}")
+		params = append(params, openAPIParameter{Name: name, In: "path", Required: true, Schema: &openAPISchema{Type: "string"}})
+	}
+	return params
+}
+
+// queryParameters documents the `query` tagged fields of a request struct.
+func (doc *openAPIDocument) queryParameters(req any) []openAPIParameter {
+	if req == nil {
+		return nil
+	}
+	var params []openAPIParameter
+	for _, f := range reflect.VisibleFields(reflect.TypeOf(req)) {
+		name, ok := f.Tag.Lookup("query")
+		if !ok {
+			continue
+		}
+		s, required := doc.fieldSchema(f)
+		params = append(params, openAPIParameter{Name: name, In: "query", Required: required, Schema: s})
+	}
+	return params
+}
+
+// formSchema documents the `form` tagged fields of a request struct as a multipart body.
+func (doc *openAPIDocument) formSchema(req any) *openAPISchema {
+	s := &openAPISchema{Type: "object", Properties: map[string]*openAPISchema{}}
+	for _, f := range reflect.VisibleFields(reflect.TypeOf(req)) {
+		name, ok := f.Tag.Lookup("form")
+		if !ok {
+			continue
+		}
+		// uploaded files are sent as multipart parts, and parseAddItemRequest requires them
+		fs, required := &openAPISchema{Type: "string", Format: "binary"}, true
+		if f.Type != reflect.TypeOf(&UploadedImage{}) {
+			fs, required = doc.fieldSchema(f)
+		}
+		s.Properties[name] = fs
+		if required {
+			s.Required = append(s.Required, name)
+		}
+	}
+	return s
+}
+
+// fieldSchema documents a request field with the constraints of its `validate` tag,
+// and reports whether the field is required.
+func (doc *openAPIDocument) fieldSchema(f reflect.StructField) (*openAPISchema, bool) {
+	s := doc.schema(f.Type)
+	required := false
+	tag, ok := f.Tag.Lookup("validate")
+	if !ok {
+		return s, false
+	}
+	for _, rule := range strings.Split(tag, ",") {
+		rule, arg, _ := strings.Cut(rule, "=")
+		switch rule {
+		case "required":
+			required = true
+			if s.MinLength == nil {
+				s.MinLength = intPtr(1)
+			}
+		case "min":
+			s.MinLength = intPtr(mustAtoi(arg))
+		case "max":
+			s.MaxLength = intPtr(mustAtoi(arg))
+		}
+	}
+	return s, required
+}
+
+func intPtr(n int) *int {
+	return &n
+}
+
+// schema documents a Go type by its json tags. Named structs are added to the components and referred to,
+// so that clients can generate a type for each of them.
+func (doc *openAPIDocument) schema(t reflect.Type) *openAPISchema {
+	switch t.Kind() {
+	case reflect.Pointer:
+		return doc.schema(t.Elem())
+	case reflect.String:
+		return &openAPISchema{Type: "string"}
+	case reflect.Bool:
+		return &openAPISchema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &openAPISchema{Type: "integer"}
+	case reflect.Int64, reflect.Uint, reflect.Uint64:
+		return &openAPISchema{Type: "integer", Format: "int64"}
+	case reflect.Float32, reflect.Float64:
+		return &openAPISchema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		return &openAPISchema{Type: "array", Items: doc.schema(t.Elem())}
+	case reflect.Map:
+		return &openAPISchema{Type: "object", AdditionalProperties: doc.schema(t.Elem())}
+	case reflect.Struct:
+		if _, ok := doc.Components.Schemas[t.Name()]; !ok {
+			// reserve the name first so that recursive types terminate
+			doc.Components.Schemas[t.Name()] = nil
+			doc.Components.Schemas[t.Name()] = doc.objectSchema(t)
+		}
+		return &openAPISchema{Ref: "#/components/schemas/" + t.Name()}
+	}
+	panic(fmt.Sprintf("openapi: unsupported type %s", t))
+}
+
+// objectSchema documents the fields of a struct as encoding/json would encode them.
+// Fields of embedded structs are promoted, and fields without omitempty are required.
+func (doc *openAPIDocument) objectSchema(t reflect.Type) *openAPISchema {
+	s := &openAPISchema{Type: "object", Properties: map[string]*openAPISchema{}}
+	for _, f := range reflect.VisibleFields(t) {
+		if !f.IsExported() || f.Anonymous {
+			continue
+		}
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = f.Name
+		}
+		s.Properties[name] = doc.schema(f.Type)
+		if !slices.Contains(strings.Split(opts, ","), "omitempty") {
+			s.Required = append(s.Required, name)
+		}
+	}
+	return s
+}
+
+// ServeHTTP is a handler to return the spec for GET /openapi.json .
+func (doc *openAPIDocument) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(doc)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+}
+
+// docsPage is a Swagger UI page to browse the spec at /openapi.json.
+//
+//go:embed docs.html
+var docsPage []byte
+
+// swaggerUI holds the Swagger UI assets loaded by docsPage, vendored so that the page doesn't depend on a CDN.
+//
+//go:generate sh -c "curl -fsSL https://registry.npmjs.org/swagger-ui-dist/-/swagger-ui-dist-5.17.14.tgz | tar -xzf - -C swaggerui --strip-components=1 package/swagger-ui.css package/swagger-ui-bundle.js package/LICENSE"
+//go:embed swaggerui
+var swaggerUI embed.FS
+
+// serveDocs is a handler to return the Swagger UI page for GET /docs .
+func serveDocs(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(docsPage)
+}
+
+// serveDocsAsset is a handler to return a Swagger UI asset for GET /docs/{file} .
+func serveDocsAsset(w http.ResponseWriter, r *http.Request) {
+	name := "swaggerui/" + r.PathValue("file")
+	if _, err := fs.Stat(swaggerUI, name); err != nil {
+		writeError(w, r, notFound(errors.New("asset not found")))
+		return
+	}
+	http.ServeFileFS(w, r, swaggerUI, name)
+}
@@ -0,0 +1,143 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// openAPISpecPath is the checked-in spec, from which clients such as the web frontend can generate their types.
+const openAPISpecPath = "../openapi.json"
+
+var updateOpenAPI = flag.Bool("update-openapi", false, "update "+openAPISpecPath+" with the generated spec")
+
+// TestOpenAPISpec fails when the routes or their request and response types change
+// without updating the checked-in spec. Run `go test ./app -run TestOpenAPISpec -update-openapi` to update it.
+func TestOpenAPISpec(t *testing.T) {
+	h := &Handlers{}
+	rs := routes(h, NewMetrics(nil))
+	mux := newMux(rs, DefaultConfig(), nil)
+
+	res := httptest.NewRecorder()
+	mux.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	if res.Code != http.StatusOK {
+		t.Fatalf("expected status code %d, got %d", http.StatusOK, res.Code)
+	}
+	var got bytes.Buffer
+	if err := json.Indent(&got, res.Body.Bytes(), "", "  "); err != nil {
+		t.Fatalf("invalid spec: %v", err)
+	}
+
+	if *updateOpenAPI {
+		if err := os.WriteFile(openAPISpecPath, got.Bytes(), 0o644); err != nil {
+			t.Fatalf("failed to update spec: %v", err)
+		}
+	}
+	want, err := os.ReadFile(openAPISpecPath)
+	if err != nil {
+		t.Fatalf("failed to read spec: %v", err)
+	}
+	if diff := cmp.Diff(string(want), got.String()); diff != "" {
+		t.Errorf("%s is out of date, run the test with -update-openapi (-want +got):\n%s", openAPISpecPath, diff)
+	}
+}
+
+// TestOpenAPIRoutes checks that every operation in the spec is served by the route it documents,
+// and that every route but the deprecated aliases is documented.
+func TestOpenAPIRoutes(t *testing.T) {
+	t.Parallel()
+
+	rs := routes(&Handlers{}, NewMetrics(nil))
+	mux := newMux(rs, DefaultConfig(), nil)
+	spec := newOpenAPISpec(rs)
+
+	wildcard := regexp.MustCompile(`\{[^}]+\}`)
+	operations := 0
+	for path, ops := range spec.Paths {
+		for method, op := range ops {
+			operations++
+			if op.Summary == "" || op.OperationID == "" {
+				t.Errorf("%s %s: missing summary or operation id", method, path)
+			}
+			if _, ok := op.Responses["200"]; !ok {
+				t.Errorf("%s %s: missing successful response", method, path)
+			}
+
+			req := httptest.NewRequest(strings.ToUpper(method), wildcard.ReplaceAllString(path, "x"), nil)
+			_, pattern := mux.Handler(req)
+			servedMethod, servedPath, _ := strings.Cut(pattern, " ")
+			if servedMethod != strings.ToUpper(method) || openAPIPath(servedPath) != path {
+				t.Errorf("%s %s: served by %q", method, path, pattern)
+			}
+		}
+	}
+
+	documented := 0
+	for _, rt := range rs {
+		if rt.successor != "" {
+			// deprecated aliases aren't documented, but they must still be served
+			req := httptest.NewRequest(rt.method, wildcard.ReplaceAllString(openAPIPath(rt.path), "x"), nil)
+			if _, pattern := mux.Handler(req); pattern != rt.method+" "+rt.path {
+				t.Errorf("%s %s: served by %q", rt.method, rt.path, pattern)
+			}
+			continue
+		}
+		documented++
+	}
+	if operations != documented {
+		t.Errorf("expected %d operations, got %d", documented, operations)
+	}
+}
+
+func TestServeDocsAsset(t *testing.T) {
+	t.Parallel()
+
+	type wants struct {
+		code        int
+		contentType string // not checked if empty, since the type of a vendored file depends on its extension
+	}
+
+	cases := map[string]struct {
+		file string
+		wants
+	}{
+		"ok: vendored file": {
+			file:  "README.md",
+			wants: wants{code: http.StatusOK},
+		},
+		"ng: missing file": {
+			file:  "missing.js",
+			wants: wants{code: http.StatusNotFound, contentType: "application/json"},
+		},
+		"ng: outside the assets": {
+			file:  "..",
+			wants: wants{code: http.StatusNotFound, contentType: "application/json"},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest(http.MethodGet, "/docs/x", nil)
+			req.SetPathValue("file", tc.file)
+			res := httptest.NewRecorder()
+			serveDocsAsset(res, req)
+
+			if res.Code != tc.wants.code {
+				t.Errorf("expected status code %d, got %d", tc.wants.code, res.Code)
+			}
+			if got := res.Header().Get("Content-Type"); tc.wants.contentType != "" && got != tc.wants.contentType {
+				t.Errorf("expected content type %q, got %q", tc.wants.contentType, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,187 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// rateLimitSweepInterval is how often the memory store forgets clients whose buckets are full again.
+const rateLimitSweepInterval = time.Minute
+
+// RateLimit is a token bucket policy: a client can make Burst requests at once,
+// and the bucket refills at PerMinute tokens per minute.
+type RateLimit struct {
+	PerMinute int
+	Burst     int
+}
+
+// RateLimitResult is the state of a bucket after taking a token.
+type RateLimitResult struct {
+	Allowed   bool
+	Limit     int           // size of the bucket
+	Remaining int           // tokens left
+	Reset     time.Duration // time until the bucket is full again
+	// RetryAfter is the time until the next token is available. It is zero if the request was allowed.
+	RetryAfter time.Duration
+}
+
+// RateLimitStore keeps the token buckets of clients.
+// The memory store works for a single server; a shared store, e.g. on Redis, can implement it for several servers.
+type RateLimitStore interface {
+	// Take takes a token from the bucket of key at now.
+	Take(ctx context.Context, key string, limit RateLimit, now time.Time) (RateLimitResult, error)
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+	full   time.Time // when the bucket is full again
+}
+
+// memoryRateLimitStore is a RateLimitStore in memory.
+type memoryRateLimitStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+func newMemoryRateLimitStore() *memoryRateLimitStore {
+	return &memoryRateLimitStore{buckets: map[string]*bucket{}}
+}
+
+func (s *memoryRateLimitStore) Take(ctx context.Context, key string, limit RateLimit, now time.Time) (RateLimitResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rate := float64(limit.PerMinute) / 60 // tokens per second
+	burst := float64(limit.Burst)
+	s.sweep(now)
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: burst, last: now}
+		s.buckets[key] = b
+	}
+	b.tokens = math.Min(burst, b.tokens+now.Sub(b.last).Seconds()*rate)
+	b.last = now
+
+	result := RateLimitResult{Limit: limit.Burst}
+	if b.tokens >= 1 {
+		b.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = secondsToDuration((1 - b.tokens) / rate)
+	}
+	result.Remaining = int(b.tokens)
+	result.Reset = secondsToDuration((burst - b.tokens) / rate)
+	b.full = now.Add(result.Reset)
+	return result, nil
+}
+
+// sweep forgets the buckets which are full by now, since a new bucket would be the same.
+// This keeps memory from growing with every client ever seen. The caller must hold s.mu.
+func (s *memoryRateLimitStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < rateLimitSweepInterval {
+		return
+	}
+	s.lastSweep = now
+	for key, b := range s.buckets {
+		if !now.Before(b.full) {
+			delete(s.buckets, key)
+		}
+	}
+}
+
+func secondsToDuration(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+// errRateLimited is returned when a client has used up its budget.
+var errRateLimited = errors.New("too many requests")
+
+// rateLimiter limits the requests of each client by named policies such as "write" or "search".
+type rateLimiter struct {
+	store    RateLimitStore
+	policies map[string]RateLimit
+	// clientKey identifies the client of a request.
+	clientKey func(r *http.Request) string
+	now       func() time.Time
+}
+
+// newRateLimiter creates a rateLimiter with the policies in the configuration.
+// Clients are identified by IP address since there is no authentication yet;
+// clientKey can be replaced to identify them by API key or user instead.
+func newRateLimiter(cfg Config, store RateLimitStore) *rateLimiter {
+	return &rateLimiter{
+		store: store,
+		policies: map[string]RateLimit{
+			"write":  {PerMinute: cfg.RateLimitWrite, Burst: cfg.RateLimitWriteBurst},
+			"search": {PerMinute: cfg.RateLimitSearch, Burst: cfg.RateLimitSearchBurst},
+			"image":  {PerMinute: cfg.RateLimitImage, Burst: cfg.RateLimitImageBurst},
+		},
+		clientKey: func(r *http.Request) string {
+			return "ip:" + clientIP(r, cfg.TrustProxyHeaders)
+		},
+		now: time.Now,
+	}
+}
+
+// clientIP returns the IP address of the client. If trustProxy is set, it is taken from X-Forwarded-For,
+// whose last address is the one seen by the proxy in front of this server.
+func clientIP(r *http.Request, trustProxy bool) string {
+	if trustProxy {
+		if ip := lastForwardedValue(r.Header, "X-Forwarded-For"); net.ParseIP(ip) != nil {
+			return ip
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// middleware limits the requests to next by the named policy.
+// Every response tells the client its budget in X-RateLimit-* headers, and 429 also tells when to retry.
+// If the store fails, requests are let through rather than taking the API down.
+func (l *rateLimiter) middleware(next http.Handler, policy string) http.Handler {
+	limit, ok := l.policies[policy]
+	if !ok {
+		panic(fmt.Sprintf("unknown rate limit policy %q", policy))
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		key := policy + ":" + l.clientKey(r)
+		result, err := l.store.Take(ctx, key, limit, l.now())
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to check rate limit: ", "error", err)
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		h := w.Header()
+		h.Set("X-RateLimit-Limit", strconv.Itoa(result.Limit))
+		h.Set("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		h.Set("X-RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+		if !result.Allowed {
+			retryAfter := ceilSeconds(result.RetryAfter)
+			h.Set("Retry-After", strconv.Itoa(retryAfter))
+			slog.WarnContext(ctx, "rate limited", "policy", policy, "client", l.clientKey(r))
+			writeError(w, r, tooManyRequests(fmt.Errorf("%w, retry after %d seconds", errRateLimited, retryAfter)))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
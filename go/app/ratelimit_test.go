@@ -0,0 +1,148 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestMemoryRateLimitStore(t *testing.T) {
+	t.Parallel()
+
+	store := newMemoryRateLimitStore()
+	limit := RateLimit{PerMinute: 60, Burst: 2} // a token per second
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	steps := []struct {
+		at   time.Duration // since start
+		key  string
+		want RateLimitResult
+	}{
+		{at: 0, key: "a", want: RateLimitResult{Allowed: true, Limit: 2, Remaining: 1, Reset: time.Second}},
+		{at: 0, key: "a", want: RateLimitResult{Allowed: true, Limit: 2, Remaining: 0, Reset: 2 * time.Second}},
+		{at: 0, key: "a", want: RateLimitResult{Allowed: false, Limit: 2, Remaining: 0, Reset: 2 * time.Second, RetryAfter: time.Second}},
+		// other clients have their own buckets
+		{at: 0, key: "b", want: RateLimitResult{Allowed: true, Limit: 2, Remaining: 1, Reset: time.Second}},
+		// half a token has been refilled
+		{at: 500 * time.Millisecond, key: "a", want: RateLimitResult{Allowed: false, Limit: 2, Remaining: 0, Reset: 1500 * time.Millisecond, RetryAfter: 500 * time.Millisecond}},
+		{at: time.Second, key: "a", want: RateLimitResult{Allowed: true, Limit: 2, Remaining: 0, Reset: 2 * time.Second}},
+		// the bucket doesn't grow beyond the burst
+		{at: time.Hour, key: "a", want: RateLimitResult{Allowed: true, Limit: 2, Remaining: 1, Reset: time.Second}},
+	}
+
+	for i, step := range steps {
+		got, err := store.Take(context.Background(), step.key, limit, start.Add(step.at))
+		if err != nil {
+			t.Fatalf("step %d: unexpected error: %v", i, err)
+		}
+		if diff := cmp.Diff(step.want, got); diff != "" {
+			t.Errorf("step %d: unexpected result (-want +got):\n%s", i, diff)
+		}
+	}
+
+	// full buckets are forgotten
+	if _, ok := store.buckets["b"]; ok {
+		t.Errorf("expected the full bucket of b to be swept")
+	}
+}
+
+type failingRateLimitStore struct{}
+
+func (failingRateLimitStore) Take(ctx context.Context, key string, limit RateLimit, now time.Time) (RateLimitResult, error) {
+	return RateLimitResult{}, errors.New("store is down")
+}
+
+func TestRateLimiterMiddleware(t *testing.T) {
+	t.Parallel()
+
+	cfg := DefaultConfig()
+	cfg.RateLimitWrite = 1
+	cfg.RateLimitWriteBurst = 1
+	cfg.TrustProxyHeaders = true
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	limiter := newRateLimiter(cfg, newMemoryRateLimitStore())
+	h := limiter.middleware(ok, "write")
+
+	post := func(forwardedFor string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/items", nil)
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		return rr
+	}
+
+	first := post("198.51.100.1, 203.0.113.1")
+	if first.Code != http.StatusOK {
+		t.Fatalf("expected status code %d, got %d", http.StatusOK, first.Code)
+	}
+	if got := first.Header().Get("X-RateLimit-Remaining"); got != "0" {
+		t.Errorf("expected X-RateLimit-Remaining 0, got %q", got)
+	}
+
+	// the client can't get a new budget by forging the first address
+	second := post("192.0.2.99, 203.0.113.1")
+	if second.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected status code %d, got %d", http.StatusTooManyRequests, second.Code)
+	}
+	if got := second.Header().Get("Retry-After"); got != "60" {
+		t.Errorf("expected Retry-After 60, got %q", got)
+	}
+	if got := second.Header().Get("X-RateLimit-Limit"); got != "1" {
+		t.Errorf("expected X-RateLimit-Limit 1, got %q", got)
+	}
+
+	if third := post("203.0.113.2"); third.Code != http.StatusOK {
+		t.Errorf("expected another client to be allowed, got %d", third.Code)
+	}
+
+	// requests are let through if the store fails
+	failOpen := (&rateLimiter{
+		store:     failingRateLimitStore{},
+		policies:  limiter.policies,
+		clientKey: limiter.clientKey,
+		now:       time.Now,
+	}).middleware(ok, "write")
+	rr := httptest.NewRecorder()
+	failOpen.ServeHTTP(rr, httptest.NewRequest("POST", "/items", nil))
+	if rr.Code != http.StatusOK {
+		t.Errorf("expected status code %d when the store fails, got %d", http.StatusOK, rr.Code)
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		remoteAddr   string
+		forwardedFor string
+		trustProxy   bool
+		want         string
+	}{
+		"ok: remote address":      {remoteAddr: "192.0.2.1:1234", want: "192.0.2.1"},
+		"ok: untrusted header":    {remoteAddr: "192.0.2.1:1234", forwardedFor: "203.0.113.1", want: "192.0.2.1"},
+		"ok: trusted header":      {remoteAddr: "192.0.2.1:1234", forwardedFor: "198.51.100.1, 203.0.113.1", trustProxy: true, want: "203.0.113.1"},
+		"ng: invalid header":      {remoteAddr: "192.0.2.1:1234", forwardedFor: "unknown", trustProxy: true, want: "192.0.2.1"},
+		"ok: ipv6 remote address": {remoteAddr: "[2001:db8::1]:1234", want: "2001:db8::1"},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest("GET", "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			if tt.forwardedFor != "" {
+				req.Header.Set("X-Forwarded-For", tt.forwardedFor)
+			}
+			if got := clientIP(req, tt.trustProxy); got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,209 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ItemResource is the representation of an item returned by every endpoint, so that
+// an item in a list or search result can be used like one from GET /items/{id}.
+type ItemResource struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	Category  string    `json:"category"`
+	ImageName string    `json:"image_name"`
+	ImageURL  string    `json:"image_url"`
+	Links     ItemLinks `json:"links"`
+
+	// fields are the fields to encode, or nil for all of them.
+	fields fieldSet
+}
+
+// ItemLinks are the URLs of the resources related to an item.
+type ItemLinks struct {
+	Self     string `json:"self"`
+	Category string `json:"category"` // the items in the same category
+}
+
+// newItemResource returns the representation of item with only the given fields.
+func newItemResource(item Item, urls itemURLs, fields fieldSet) ItemResource {
+	return ItemResource{
+		ID:        item.ID,
+		Name:      item.Name,
+		Category:  item.Category,
+		ImageName: item.ImageName,
+		ImageURL:  urls.image(item.ImageName),
+		Links: ItemLinks{
+			Self:     urls.item(item.ID),
+			Category: urls.category(item.Category),
+		},
+		fields: fields,
+	}
+}
+
+// newItemResources returns the representations of items. It never returns nil, so that an empty list is encoded as [].
+func newItemResources(items []Item, urls itemURLs, fields fieldSet) []ItemResource {
+	resources := make([]ItemResource, 0, len(items))
+	for _, item := range items {
+		resources = append(resources, newItemResource(item, urls, fields))
+	}
+	return resources
+}
+
+// itemURLs builds the absolute URLs in item representations, so that clients don't need to know the routes.
+type itemURLs struct {
+	base string // public base URL without a trailing slash, e.g. "https://api.example.com"
+}
+
+func (u itemURLs) item(id int) string {
+	return u.base + apiPrefix + "/items/" + strconv.Itoa(id)
+}
+
+func (u itemURLs) image(name string) string {
+	return u.base + apiPrefix + "/images/" + url.PathEscape(name)
+}
+
+func (u itemURLs) category(name string) string {
+	return u.base + apiPrefix + "/items?" + url.Values{"category": {name}}.Encode()
+}
+
+// itemURLs returns the builder of the URLs in the responses to r.
+func (s *Handlers) itemURLs(r *http.Request) itemURLs {
+	return itemURLs{base: publicBaseURL(r, s.publicURL, s.trustProxyHeaders)}
+}
+
+// publicBaseURL returns the base URL of the API as seen by the client of r.
+// The configured URL is used as is, e.g. when the server is behind a CDN. Otherwise it is derived from the request,
+// taking X-Forwarded-Proto and X-Forwarded-Host into account if trustProxy is set.
+// If the Host header isn't a valid host, it returns "" so that the URLs are relative to the server
+// instead of carrying whatever the client sent.
+func publicBaseURL(r *http.Request, configured string, trustProxy bool) string {
+	if configured != "" {
+		return strings.TrimSuffix(configured, "/")
+	}
+	scheme, host := "http", r.Host
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if trustProxy {
+		if proto := strings.ToLower(lastForwardedValue(r.Header, "X-Forwarded-Proto")); proto == "http" || proto == "https" {
+			scheme = proto
+		}
+		if h := lastForwardedValue(r.Header, "X-Forwarded-Host"); validHost(h) {
+			host = h
+		}
+	}
+	if !validHost(host) {
+		return ""
+	}
+	return scheme + "://" + host
+}
+
+// lastForwardedValue returns the last value of a comma-separated X-Forwarded-* header, or "".
+// The last value is the one set by the proxy in front of this server, while the others can be forged by the client.
+func lastForwardedValue(h http.Header, name string) string {
+	values := h.Values(name)
+	if len(values) == 0 {
+		return ""
+	}
+	parts := strings.Split(values[len(values)-1], ",")
+	return strings.TrimSpace(parts[len(parts)-1])
+}
+
+// validHost reports whether h is a host with an optional port, and nothing else.
+func validHost(h string) bool {
+	u, err := url.Parse("//" + h)
+	return err == nil && h != "" && u.Host == h && u.User == nil
+}
+
+func (it ItemResource) MarshalJSON() ([]byte, error) {
+	type plain ItemResource // without this method
+	b, err := json.Marshal(plain(it))
+	if err != nil || it.fields == nil {
+		return b, err
+	}
+	return it.fields.filter(b)
+}
+
+// itemFields are the JSON names of the fields of ItemResource.
+var itemFields = jsonFieldNames(reflect.TypeFor[ItemResource]())
+
+// fieldSet is a set of fields selected by a `fields` query parameter, e.g. "id,name".
+type fieldSet map[string]bool
+
+// parseFieldSet parses a comma-separated list of fields out of allowed.
+// It returns nil, which selects all fields, if s is empty.
+func parseFieldSet(s string, allowed []string) (fieldSet, error) {
+	if s == "" {
+		return nil, nil
+	}
+	fs := fieldSet{}
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		if !slices.Contains(allowed, name) {
+			return nil, fmt.Errorf("has an unknown field %q, must only list: %s", name, strings.Join(allowed, ", "))
+		}
+		fs[name] = true
+	}
+	return fs, nil
+}
+
+// filter removes the members of a JSON object which aren't in the set, keeping the order of the others.
+func (fs fieldSet) filter(object []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(object))
+	if _, err := dec.Token(); err != nil { // {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return nil, err
+		}
+		name := key.(string)
+		if !fs[name] {
+			continue
+		}
+		if buf.Len() > 1 {
+			buf.WriteByte(',')
+		}
+		k, _ := json.Marshal(name)
+		buf.Write(k)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// jsonFieldNames returns the names of the fields of a struct type in JSON.
+func jsonFieldNames(t reflect.Type) []string {
+	var names []string
+	for _, f := range reflect.VisibleFields(t) {
+		if !f.IsExported() || f.Anonymous {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		switch name {
+		case "-":
+			continue
+		case "":
+			name = f.Name
+		}
+		names = append(names, name)
+	}
+	return names
+}
@@ -0,0 +1,178 @@
+package app
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestItemResourceJSON(t *testing.T) {
+	t.Parallel()
+
+	item := Item{ID: 1, Name: "jacket", Category: "fashion & bags", ImageName: "a b.jpg"}
+	urls := itemURLs{base: "https://api.example.com"}
+
+	type wants struct {
+		json string
+		err  bool
+	}
+	cases := map[string]struct {
+		fields string
+		wants
+	}{
+		"ok: all fields": {
+			fields: "",
+			wants: wants{
+				json: `{"id":1,"name":"jacket","category":"fashion \u0026 bags","image_name":"a b.jpg",` +
+					`"image_url":"https://api.example.com/v1/images/a%20b.jpg",` +
+					`"links":{"self":"https://api.example.com/v1/items/1","category":"https://api.example.com/v1/items?category=fashion+%26+bags"}}`,
+			},
+		},
+		"ok: selected fields in the order of the resource": {
+			fields: "links, id",
+			wants: wants{
+				json: `{"id":1,"links":{"self":"https://api.example.com/v1/items/1","category":"https://api.example.com/v1/items?category=fashion+%26+bags"}}`,
+			},
+		},
+		"ng: unknown field": {
+			fields: "id,price",
+			wants: wants{
+				err: true,
+			},
+		},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			fields, err := parseFieldSet(tt.fields, itemFields)
+			if err != nil {
+				if !tt.err {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if tt.err {
+				t.Fatalf("expected an error, got none")
+			}
+
+			got, err := json.Marshal(newItemResource(item, urls, fields))
+			if err != nil {
+				t.Fatalf("failed to marshal: %v", err)
+			}
+			if string(got) != tt.json {
+				t.Errorf("expected %s, got %s", tt.json, got)
+			}
+		})
+	}
+}
+
+func TestSimilarItemJSON(t *testing.T) {
+	t.Parallel()
+
+	item := Item{ID: 1, Name: "jacket", Category: "fashion", ImageName: "a.jpg"}
+	urls := itemURLs{base: "https://api.example.com"}
+
+	cases := map[string]struct {
+		fields fieldSet
+		want   string
+	}{
+		"ok: all fields": {
+			want: `{"id":1,"name":"jacket","category":"fashion","image_name":"a.jpg",` +
+				`"image_url":"https://api.example.com/v1/images/a.jpg",` +
+				`"links":{"self":"https://api.example.com/v1/items/1","category":"https://api.example.com/v1/items?category=fashion"},` +
+				`"distance":3}`,
+		},
+		"ok: no fields of the item": {
+			fields: fieldSet{},
+			want:   `{"distance":3}`,
+		},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := json.Marshal(SimilarItem{ItemResource: newItemResource(item, urls, tt.fields), Distance: 3})
+			if err != nil {
+				t.Fatalf("failed to marshal: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestPublicBaseURL(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		configured string
+		trustProxy bool
+		tls        bool
+		host       string // Host header, example.com if empty
+		headers    map[string]string
+		want       string
+	}{
+		"ok: configured URL": {
+			configured: "https://cdn.example.com/api/",
+			headers:    map[string]string{"X-Forwarded-Host": "evil.example.com"},
+			want:       "https://cdn.example.com/api",
+		},
+		"ok: request host": {
+			want: "http://example.com",
+		},
+		"ok: TLS": {
+			tls:  true,
+			want: "https://example.com",
+		},
+		"ok: forwarded headers of the last proxy": {
+			trustProxy: true,
+			headers:    map[string]string{"X-Forwarded-Proto": "https", "X-Forwarded-Host": "evil.example.com, api.example.com:8443"},
+			want:       "https://api.example.com:8443",
+		},
+		"ng: forwarded headers without trusting the proxy": {
+			headers: map[string]string{"X-Forwarded-Proto": "https", "X-Forwarded-Host": "api.example.com"},
+			want:    "http://example.com",
+		},
+		"ng: invalid request host": {
+			host: "evil.example.com/path?",
+			want: "",
+		},
+		"ng: invalid request host with a valid forwarded host": {
+			trustProxy: true,
+			host:       "evil.example.com/path",
+			headers:    map[string]string{"X-Forwarded-Host": "api.example.com"},
+			want:       "http://api.example.com",
+		},
+		"ng: invalid forwarded headers": {
+			trustProxy: true,
+			headers:    map[string]string{"X-Forwarded-Proto": "javascript", "X-Forwarded-Host": "evil.example.com/path"},
+			want:       "http://example.com",
+		},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest(http.MethodGet, "/v1/items", nil)
+			if tt.host != "" {
+				req.Host = tt.host
+			}
+			if tt.tls {
+				req.TLS = &tls.ConnectionState{}
+			}
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			if got := publicBaseURL(req, tt.configured, tt.trustProxy); got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,311 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// route is an endpoint of the API.
+// Routes are registered to the mux and documented in the OpenAPI spec from the same table,
+// so that the spec can't miss a route or describe one which doesn't exist.
+type route struct {
+	method  string
+	path    string // ServeMux pattern without the method, e.g. "/items/{id}"
+	handler http.HandlerFunc
+	// upload allows a request body large enough for an image; other routes only accept small bodies.
+	upload bool
+	// rateLimit is the name of the rate limit policy of the route, or "" if it isn't limited.
+	rateLimit string
+	// successor is the path prefix of the routes replacing this deprecated route, or "" if it isn't deprecated.
+	// Deprecated routes aren't documented so that new clients don't use them.
+	successor string
+	doc       operation
+}
+
+// operation documents a route in the OpenAPI spec.
+type operation struct {
+	id      string
+	summary string
+	// query is a request struct whose `query` tagged fields are the query parameters.
+	query any
+	// form is a request struct whose `form` tagged fields make the multipart request body.
+	form any
+	// bodyTypes are the content types of a request body which is read as is, e.g. a CSV file.
+	bodyTypes []string
+	// responses are the successful responses. Error responses are added from errors, upload and rateLimit.
+	responses []response
+	// errors are the statuses the handler returns an ErrorResponse with, besides 500 which any route can return.
+	errors []int
+}
+
+// response is a documented response of an operation.
+type response struct {
+	status      int
+	description string
+	// body is a value of the JSON response body type, or nil if the body isn't JSON.
+	body any
+	// contentType is the content type of a non-JSON body.
+	contentType string
+	// otherTypes are the content types of the same response in other formats, e.g. "text/csv".
+	otherTypes []string
+}
+
+// jsonResponse documents a JSON response with the type of body.
+func jsonResponse(status int, description string, body any) response {
+	return response{status: status, description: description, body: body}
+}
+
+// apiPrefix is the path prefix of the current version of the API.
+// The routes of a new version, e.g. /v2, are added by another table like v1Routes under its own prefix,
+// sharing the repository with the older versions.
+const apiPrefix = "/v1"
+
+// The unversioned paths are aliases of the /v1 routes for the clients written before versioning.
+// They are deprecated and will be removed at unversionedSunset.
+var (
+	unversionedDeprecation = time.Date(2026, time.October, 18, 0, 0, 0, 0, time.UTC)
+	unversionedSunset      = time.Date(2027, time.April, 1, 0, 0, 0, 0, time.UTC)
+)
+
+// routes returns the routes of the server: the API under apiPrefix, its deprecated unversioned aliases,
+// and the operational routes such as health checks, which aren't versioned since they aren't used by clients.
+func routes(h *Handlers, metrics *Metrics) []route {
+	var rs []route
+	rs = append(rs, mount(apiPrefix, v1Routes(h))...)
+	rs = append(rs, unversionedAliases(apiPrefix, v1Routes(h))...)
+	rs = append(rs, opsRoutes(h, metrics)...)
+
+	// the spec documents itself too, so it is built from the whole table
+	spec := newOpenAPISpec(append(rs, openAPIRoute(nil)))
+	return append(rs, openAPIRoute(spec))
+}
+
+// v1Routes returns the routes of version 1 of the API, relative to its prefix.
+func v1Routes(h *Handlers) []route {
+	return []route{
+		{
+			method: http.MethodGet, path: "/{$}", handler: h.Hello,
+			doc: operation{
+				id: "hello", summary: "Say hello",
+				responses: []response{jsonResponse(http.StatusOK, "A greeting", HelloResponse{})},
+			},
+		},
+		{
+			method: http.MethodPost, path: "/items", handler: h.AddItem, upload: true, rateLimit: "write",
+			doc: operation{
+				id: "addItem", summary: "Add an item",
+				form:      AddItemRequest{},
+				responses: []response{jsonResponse(http.StatusOK, "All items including the added one", AddItemResponse{})},
+				errors:    []int{http.StatusBadRequest, http.StatusUnprocessableEntity},
+			},
+		},
+		{
+			method: http.MethodPost, path: "/items/import", handler: h.ImportItems, rateLimit: "write",
+			doc: operation{
+				id: "importItems", summary: "Import items from CSV or JSON Lines",
+				query:     ImportItemsRequest{},
+				bodyTypes: []string{"text/csv", "application/x-ndjson"},
+				responses: []response{jsonResponse(http.StatusOK, "The result of each row", ImportItemsResponse{})},
+				errors:    []int{http.StatusBadRequest, http.StatusRequestEntityTooLarge, http.StatusUnprocessableEntity},
+			},
+		},
+		{
+			method: http.MethodGet, path: "/items", handler: h.GetItems,
+			doc: operation{
+				id: "getItems", summary: "List items",
+				query:     GetItemsRequest{},
+				responses: []response{jsonResponse(http.StatusOK, "All items", GetItemsResponse{})},
+				errors:    []int{http.StatusUnprocessableEntity},
+			},
+		},
+		{
+			method: http.MethodGet, path: "/items/export", handler: h.ExportItems, rateLimit: "search",
+			doc: operation{
+				id: "exportItems", summary: "Export items as CSV, JSON Lines or JSON",
+				query: ExportItemsRequest{},
+				responses: []response{{
+					status: http.StatusOK, description: "The items, streamed in the order of their IDs",
+					body: GetItemsResponse{}, otherTypes: []string{"text/csv", "application/x-ndjson"},
+				}},
+				errors: []int{http.StatusUnprocessableEntity},
+			},
+		},
+		{
+			method: http.MethodGet, path: "/images/{filename}", handler: h.GetImage, rateLimit: "image",
+			doc: operation{
+				id: "getImage", summary: "Get an image, or the default image if it doesn't exist",
+				responses: []response{
+					{status: http.StatusOK, description: "The image", contentType: "image/jpeg"},
+					{status: http.StatusNotModified, description: "The cached image is up to date"},
+				},
+				errors: []int{http.StatusBadRequest},
+			},
+		},
+		{
+			method: http.MethodGet, path: "/images/{filename}/meta", handler: h.GetImageMeta, rateLimit: "image",
+			doc: operation{
+				id: "getImageMeta", summary: "Get the metadata of an image",
+				responses: []response{jsonResponse(http.StatusOK, "The metadata of the image", ImageInfo{})},
+				errors:    []int{http.StatusBadRequest, http.StatusNotFound},
+			},
+		},
+		{
+			method: http.MethodGet, path: "/items/{id}", handler: h.GetItemDetail,
+			doc: operation{
+				id: "getItemDetail", summary: "Get an item",
+				query:     GetItemDetailRequest{},
+				responses: []response{jsonResponse(http.StatusOK, "The item", ItemResource{})},
+				errors:    []int{http.StatusBadRequest, http.StatusNotFound, http.StatusUnprocessableEntity},
+			},
+		},
+		{
+			method: http.MethodGet, path: "/items/{id}/similar-images", handler: h.GetSimilarImages,
+			doc: operation{
+				id: "getSimilarImages", summary: "List items whose image looks like the image of an item",
+				query:     GetSimilarImagesRequest{},
+				responses: []response{jsonResponse(http.StatusOK, "Similar items, closest first", GetSimilarImagesResponse{})},
+				errors:    []int{http.StatusBadRequest, http.StatusNotFound},
+			},
+		},
+		{
+			method: http.MethodGet, path: "/search", handler: h.Search, rateLimit: "search",
+			doc: operation{
+				id: "searchItems", summary: "Search items by keyword",
+				query:     SearchItemsRequest{},
+				responses: []response{jsonResponse(http.StatusOK, "Items whose name contains the keyword", SearchItemsResponse{})},
+				errors:    []int{http.StatusUnprocessableEntity},
+			},
+		},
+	}
+}
+
+// opsRoutes returns the operational routes, which are served at the root.
+func opsRoutes(h *Handlers, metrics *Metrics) []route {
+	return []route{
+		{
+			method: http.MethodGet, path: "/healthz", handler: h.Healthz,
+			doc: operation{
+				id: "healthz", summary: "Tell that the process is alive",
+				responses: []response{jsonResponse(http.StatusOK, "The process is alive", HealthResponse{})},
+			},
+		},
+		{
+			method: http.MethodGet, path: "/readyz", handler: h.Readyz,
+			doc: operation{
+				id: "readyz", summary: "Tell if the server can serve requests",
+				responses: []response{
+					jsonResponse(http.StatusOK, "All dependencies are available", ReadinessResponse{}),
+					jsonResponse(http.StatusServiceUnavailable, "A dependency is unavailable or the server is shutting down", ReadinessResponse{}),
+				},
+			},
+		},
+		{
+			method: http.MethodGet, path: "/metrics", handler: metrics.ServeHTTP,
+			doc: operation{
+				id: "metrics", summary: "Get metrics in the Prometheus text format",
+				responses: []response{{status: http.StatusOK, description: "The metrics", contentType: metricsContentType}},
+			},
+		},
+		{
+			method: http.MethodGet, path: "/docs", handler: serveDocs,
+			doc: operation{
+				id: "docs", summary: "Browse this specification with Swagger UI",
+				responses: []response{{status: http.StatusOK, description: "The Swagger UI page", contentType: "text/html"}},
+			},
+		},
+		{
+			method: http.MethodGet, path: "/docs/{file}", handler: serveDocsAsset,
+			doc: operation{
+				id: "docsAsset", summary: "Get a Swagger UI asset loaded by the docs page",
+				responses: []response{{status: http.StatusOK, description: "The asset", contentType: "text/css", otherTypes: []string{"text/javascript"}}},
+				errors:    []int{http.StatusNotFound},
+			},
+		},
+	}
+}
+
+// mount returns the routes under prefix.
+func mount(prefix string, rs []route) []route {
+	mounted := make([]route, len(rs))
+	for i, rt := range rs {
+		rt.path = prefix + rt.path
+		mounted[i] = rt
+	}
+	return mounted
+}
+
+// unversionedAliases returns the routes at the root as deprecated aliases of the routes under prefix.
+func unversionedAliases(prefix string, rs []route) []route {
+	aliases := make([]route, len(rs))
+	for i, rt := range rs {
+		rt.successor = prefix
+		aliases[i] = rt
+	}
+	return aliases
+}
+
+// openAPIRoute returns the route serving spec.
+func openAPIRoute(spec *openAPIDocument) route {
+	return route{
+		method: http.MethodGet, path: "/openapi.json", handler: spec.ServeHTTP,
+		doc: operation{
+			id: "openapi", summary: "Get this OpenAPI specification",
+			responses: []response{{status: http.StatusOK, description: "The OpenAPI 3 document", contentType: "application/json"}},
+		},
+	}
+}
+
+// newMux registers the routes to a new ServeMux.
+// The request body is limited per route: large enough for an image upload, small elsewhere.
+// limiter may be nil to disable rate limiting.
+func newMux(rs []route, cfg Config, limiter *rateLimiter) *http.ServeMux {
+	mux := http.NewServeMux()
+	for _, rt := range rs {
+		limit := cfg.MaxBodyBytes
+		if rt.upload {
+			limit = cfg.MaxImageBytes + maxMultipartOverhead
+		}
+		h := maxBytesMiddleware(rt.handler, limit)
+		if rt.rateLimit != "" && limiter != nil {
+			h = limiter.middleware(h, rt.rateLimit)
+		}
+		if rt.successor != "" {
+			h = deprecatedMiddleware(h, rt.successor, unversionedDeprecation, unversionedSunset)
+		}
+		mux.Handle(rt.method+" "+rt.path, h)
+	}
+	mux.Handle(fallbackPattern, maxBytesMiddleware(fallbackHandler(mux), cfg.MaxBodyBytes))
+	return mux
+}
+
+// fallbackPattern matches the requests which no route matches.
+const fallbackPattern = "/"
+
+// fallbackHandler returns a handler for the requests which no route of mux matches,
+// so that they get an ErrorResponse instead of the plain text errors of ServeMux:
+// 405 with the allowed methods if the path is served with other methods, and 404 otherwise.
+func fallbackHandler(mux *http.ServeMux) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var allowed []string
+		for _, method := range []string{http.MethodGet, http.MethodHead, http.MethodPost} {
+			req := r.Clone(r.Context())
+			req.Method = method
+			if _, pattern := mux.Handler(req); pattern != fallbackPattern {
+				allowed = append(allowed, method)
+			}
+		}
+		if len(allowed) == 0 {
+			writeError(w, r, notFound(errors.New("no route matches the path")))
+			return
+		}
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
+		writeError(w, r, &APIError{
+			Status:  http.StatusMethodNotAllowed,
+			Code:    codeMethodNotAllowed,
+			Message: fmt.Sprintf("method %s is not allowed, must be one of %s", r.Method, strings.Join(allowed, ", ")),
+		})
+	}
+}
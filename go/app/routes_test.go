@@ -0,0 +1,96 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestNewMuxFallback(t *testing.T) {
+	t.Parallel()
+
+	type wants struct {
+		code  int
+		allow string
+		err   *APIError // nil if the request is served by a route
+	}
+
+	cases := map[string]struct {
+		method string
+		path   string
+		wants
+	}{
+		"ok: route is served": {
+			method: http.MethodGet,
+			path:   "/v1/",
+			wants:  wants{code: http.StatusOK},
+		},
+		"ng: unknown path": {
+			method: http.MethodGet,
+			path:   "/v1/unknown",
+			wants: wants{
+				code: http.StatusNotFound,
+				err:  &APIError{Code: codeNotFound, Message: "no route matches the path"},
+			},
+		},
+		"ng: unknown path under a route": {
+			method: http.MethodGet,
+			path:   "/v1/items/1/unknown",
+			wants: wants{
+				code: http.StatusNotFound,
+				err:  &APIError{Code: codeNotFound, Message: "no route matches the path"},
+			},
+		},
+		"ng: method not allowed": {
+			method: http.MethodPost,
+			path:   "/healthz",
+			wants: wants{
+				code:  http.StatusMethodNotAllowed,
+				allow: "GET, HEAD",
+				err:   &APIError{Code: codeMethodNotAllowed, Message: "method POST is not allowed, must be one of GET, HEAD"},
+			},
+		},
+		"ng: method not allowed on a path served with several methods": {
+			method: http.MethodDelete,
+			path:   "/v1/items",
+			wants: wants{
+				code:  http.StatusMethodNotAllowed,
+				allow: "GET, HEAD, POST",
+				err:   &APIError{Code: codeMethodNotAllowed, Message: "method DELETE is not allowed, must be one of GET, HEAD, POST"},
+			},
+		},
+	}
+
+	mux := newMux(routes(&Handlers{}, NewMetrics(nil)), DefaultConfig(), nil)
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			res := httptest.NewRecorder()
+			mux.ServeHTTP(res, httptest.NewRequest(tc.method, tc.path, nil))
+
+			if res.Code != tc.wants.code {
+				t.Errorf("expected status code %d, got %d", tc.wants.code, res.Code)
+			}
+			if got := res.Header().Get("Allow"); got != tc.wants.allow {
+				t.Errorf("expected Allow %q, got %q", tc.wants.allow, got)
+			}
+			if tc.wants.err == nil {
+				return
+			}
+			if got := res.Header().Get("Content-Type"); got != "application/json" {
+				t.Errorf("expected a JSON error, got content type %q", got)
+			}
+			var got ErrorResponse
+			if err := json.NewDecoder(res.Body).Decode(&got); err != nil {
+				t.Fatalf("failed to decode error response: %v", err)
+			}
+			if diff := cmp.Diff(ErrorResponse{Error: tc.wants.err}, got, cmp.AllowUnexported(APIError{})); diff != "" {
+				t.Errorf("unexpected error response (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -1,74 +1,206 @@
 package app
 
 import (
+	"bytes"
 	"context"
 	"crypto/sha256"
+	"database/sql"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"log/slog"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
 )
 
 type Server struct {
-	// Port is the port number to listen on.
-	Port string
-	// ImageDirPath is the path to the directory storing images.
-	ImageDirPath string
+	// Config is the configuration of the server. Use LoadConfig or DefaultConfig to create it.
+	Config Config
 }
 
+// defaultShutdownTimeout is the default deadline to drain in-flight requests on shutdown.
+const defaultShutdownTimeout = 10 * time.Second
+
+// defaultShutdownDrainDelay is how long the readiness check fails on shutdown before new connections are refused,
+// which gives load balancers time to stop routing traffic to the server.
+const defaultShutdownDrainDelay = 5 * time.Second
+
 // Run is a method to start the server.
-// This method returns 0 if the server started successfully, and 1 otherwise.
+// It serves until SIGINT or SIGTERM is received, then drains in-flight requests and closes the database.
+// This method returns 0 if the server started and shut down successfully, and 1 otherwise.
 func (s Server) Run() int {
-	// Set up logger
-	logger := slog.New(slog.NewJSONHandler(os.Stderr, nil))
-	slog.SetDefault(logger)
-	slog.SetLogLoggerLevel(slog.LevelDebug)
-
-	// Set up CORS settings
-	frontURL, found := os.LookupEnv("FRONT_URL")
-	if !found {
-		frontURL = "http://localhost:3000"
+	cfg := s.Config
+	if err := cfg.Validate(); err != nil {
+		slog.Error("failed to start server: ", "error", err)
+		return 1
 	}
 
+	// Set up logger
+	slog.SetDefault(cfg.NewLogger())
+	slog.Info("effective config", "config", cfg)
+
 	// Set up handlers
-	itemRepo, err := NewItemRepository()
+	itemRepo, err := NewItemRepository(cfg.DatabaseDSN, cfg.SchemaPath)
 	if err != nil {
 		slog.Error("failed to create item repository: ", "error", err)
 		return 1
 	}
 
-	h := &Handlers{imgDirPath: s.ImageDirPath, itemRepo: itemRepo}
+	// Set up metrics
+	var dbStats func() sql.DBStats
+	if repo, ok := itemRepo.(interface{ Stats() sql.DBStats }); ok {
+		dbStats = repo.Stats
+	}
+	metrics := NewMetrics(dbStats)
+
+	h := &Handlers{
+		imgDirPath:        cfg.ImageDir,
+		itemRepo:          instrumentRepository(itemRepo, metrics),
+		metrics:           metrics,
+		maxImageBytes:     cfg.MaxImageBytes,
+		maxImageDimension: cfg.MaxImageDimension,
+		similarThreshold:  cfg.SimilarImageThreshold,
+		warnSimilar:       cfg.WarnSimilarImages,
+		publicURL:         cfg.PublicURL,
+		trustProxyHeaders: cfg.TrustProxyHeaders,
+	}
 
 	// Set up routes
-	mux := http.NewServeMux()
-	mux.HandleFunc("GET /", h.Hello)
-	mux.HandleFunc("POST /items", h.AddItem)
-	mux.HandleFunc("GET /items", h.GetItems)
-	mux.HandleFunc("GET /images/{filename}", h.GetImage)
-	mux.HandleFunc("GET /items/{id}", h.GetItemDetail)
-	mux.HandleFunc("GET /search", h.Search)
+	// writes, searches and image fetches have separate budgets per client
+	var limiter *rateLimiter
+	if cfg.RateLimitEnabled {
+		limiter = newRateLimiter(cfg, newMemoryRateLimitStore())
+	}
+	mux := newMux(routes(h, metrics), cfg, limiter)
+
+	cors, err := newCORSPolicy(cfg, []string{"GET", "HEAD", "POST"})
+	if err != nil {
+		slog.Error("failed to start server: ", "error", err)
+		itemRepo.Close()
+		return 1
+	}
 
+	// the request ID is set first so that every log line and error response of the request carries it.
+	// The access log and metrics see the route pattern since nothing between them and mux replaces the request,
+	// and they see the 500 of a recovered panic since recovery is innermost, as well as rejected preflights.
+	var handler http.Handler = mux
+	handler = recoverMiddleware(handler, slog.Default(), metrics)
+	handler = compressMiddleware(handler, compressMinSize)
+	handler = corsMiddleware(handler, cors)
+	handler = metricsMiddleware(handler, metrics)
+	handler = accessLogMiddleware(handler, slog.Default())
+	handler = requestIDMiddleware(handler)
+
+	// the timeouts keep slow clients from holding connections forever
+	srv := &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		ReadTimeout:       cfg.ReadTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+	}
 	// Start the server
-	slog.Info("http server started on", "port", s.Port)
-	err = http.ListenAndServe(":"+s.Port, simpleCORSMiddleware(simpleLoggerMiddleware(mux), frontURL, []string{"GET", "HEAD", "POST", "OPTIONS"}))
+	ln, err := net.Listen("tcp", cfg.Addr)
 	if err != nil {
 		slog.Error("failed to start server: ", "error", err)
+		itemRepo.Close()
 		return 1
 	}
+	slog.Info("http server started on", "addr", ln.Addr().String())
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	code := 0
+	draining := func() { h.shuttingDown.Store(true) }
+	if err := serve(ctx, srv, ln, draining, cfg.ShutdownDrainDelay, cfg.ShutdownTimeout); err != nil {
+		slog.Error("failed to serve: ", "error", err)
+		code = 1
+	}
+
+	// close the database only after all requests have finished
+	if err := itemRepo.Close(); err != nil {
+		slog.Error("failed to close item repository: ", "error", err)
+		code = 1
+	}
+
+	slog.Info("http server stopped", "exit_code", code)
+	return code
+}
+
+// serve serves HTTP requests on ln until ctx is canceled.
+// Then it calls draining to fail the readiness check and keeps serving for drainDelay, so that load balancers
+// stop routing new requests while they can still reach /readyz. Finally it stops accepting new connections
+// and waits for in-flight requests to finish within timeout.
+func serve(ctx context.Context, srv *http.Server, ln net.Listener, draining func(), drainDelay, timeout time.Duration) error {
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- srv.Serve(ln)
+	}()
+
+	select {
+	case err := <-errCh:
+		// the server stopped by itself, which means it failed
+		return err
+	case <-ctx.Done():
+	}
+
+	draining()
+	if drainDelay > 0 {
+		slog.Info("draining http server", "delay", drainDelay.String())
+		select {
+		case err := <-errCh:
+			return err
+		case <-time.After(drainDelay):
+		}
+	}
 
-	return 0
+	slog.Info("shutting down http server", "timeout", timeout.String())
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		// drop the connections which didn't finish in time
+		srv.Close()
+		return fmt.Errorf("failed to drain connections: %w", err)
+	}
+	if err := <-errCh; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
 }
 
 type Handlers struct {
 	// imgDirPath is the path to the directory storing images.
 	imgDirPath string
 	itemRepo   ItemRepository
+	// maxImageBytes and maxImageDimension limit uploaded images. Zero means the default.
+	maxImageBytes     int64
+	maxImageDimension int
+	// similarThreshold is the maximum Hamming distance of similar images.
+	// Zero only matches images which look identical, unlike the zero of the upload limits.
+	similarThreshold int
+	// warnSimilar enables the similar image warning of AddItem.
+	warnSimilar bool
+	// shuttingDown is set when the server starts to shut down, to fail the readiness check.
+	shuttingDown atomic.Bool
+	// metrics records the uploaded bytes and image cache hits. It may be nil.
+	metrics *Metrics
+	// publicURL is the base URL of the API seen by clients. If empty, it is derived from each request,
+	// honoring the X-Forwarded-* headers if trustProxyHeaders is set.
+	publicURL         string
+	trustProxyHeaders bool
 }
 
 type HelloResponse struct {
@@ -80,66 +212,95 @@ func (s *Handlers) Hello(w http.ResponseWriter, r *http.Request) {
 	resp := HelloResponse{Message: "Hello, world!"}
 	err := json.NewEncoder(w).Encode(resp)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
 }
 
 type AddItemRequest struct {
-	Name     string `form:"name"`
-	Category string `form:"category"` // Category of the item
-	Image    []byte `form:"image"`    // Image data in bytes
+	Name     string         `form:"name" validate:"trim,nfc,required,max=100,charset=text"`
+	Category string         `form:"category" validate:"trim,nfc,required,max=50,charset=text"` // Category of the item
+	Image    *UploadedImage `form:"image"`                                                     // Image spooled to a temporary file
 }
 
 type AddItemResponse struct {
-	Message string `json:"message"`
+	Message string         `json:"message,omitempty"`
+	Items   []ItemResource `json:"items"`
+	// SimilarItems lists existing items whose image looks like the new one.
+	SimilarItems []SimilarItem `json:"similar_items,omitempty"`
 }
 
+// maxFormValueSize is the maximum size of a non-file multipart field such as name.
+const maxFormValueSize = 64 << 10
+
+// maxMultipartOverhead is the room left for the fields and boundaries of a multipart body
+// in addition to the image itself.
+const maxMultipartOverhead = 1 << 20
+
 // parseAddItemRequest parses and validates the request to add an item.
-func parseAddItemRequest(r *http.Request) (*AddItemRequest, error) {
+// The uploaded image is streamed to a temporary file, so the caller must call req.Image.Remove()
+// when the request is no longer needed.
+// The errors caused by the request are APIErrors or errors mapped to 400 and 413 by writeError,
+// while any other error is a failure of the server.
+func parseAddItemRequest(r *http.Request, limits uploadLimits) (_ *AddItemRequest, err error) {
 	var req = &AddItemRequest{}
-
-	// Check if it's multipart/form-data
-	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
-		err := r.ParseMultipartForm(32 << 20) // 32MB max memory
+	defer func() {
+		// don't leave the temporary file behind if the request turns out to be invalid
 		if err != nil {
-			return nil, fmt.Errorf("failed to parse multipart form: %w", err)
+			req.Image.Remove()
 		}
+	}()
 
-		req.Name = r.FormValue("name")
-		req.Category = r.FormValue("category")
-
-		// Get the image file
-		file, header, err := r.FormFile("image")
+	// Check if it's multipart/form-data
+	isMultipart := strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data")
+	if isMultipart {
+		mr, err := r.MultipartReader()
 		if err != nil {
-			if errors.Is(err, http.ErrMissingFile) {
-				return nil, errors.New("image is required")
-			}
-			return nil, fmt.Errorf("failed to get image file: %w", err)
+			return nil, badRequest(fmt.Errorf("failed to parse multipart form: %w", err))
 		}
-		defer file.Close()
 
-		// Check file extension (optional, but good practice)
-		if !strings.HasSuffix(strings.ToLower(header.Filename), ".jpg") {
-			return nil, errors.New("only .jpg files are allowed")
-		}
+		// read the parts one by one so that the image is never held in memory as a whole
+		for {
+			part, err := mr.NextPart()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				return nil, badRequest(fmt.Errorf("failed to parse multipart form: %w", err))
+			}
 
-		// Read image data
-		imageData, err := io.ReadAll(file)
-		if err != nil {
-			return nil, fmt.Errorf("failed to read image data: %w", err)
-		}
-		if len(imageData) == 0 {
-			return nil, errors.New("image data is empty")
+			switch part.FormName() {
+			case "name", "category":
+				value, err := io.ReadAll(io.LimitReader(part, maxFormValueSize))
+				if err != nil {
+					return nil, badRequest(fmt.Errorf("failed to read %s: %w", part.FormName(), err))
+				}
+				if part.FormName() == "name" {
+					req.Name = string(value)
+				} else {
+					req.Category = string(value)
+				}
+			case "image":
+				if req.Image != nil {
+					return nil, badRequest(errors.New("only one image is allowed"))
+				}
+				// Check file extension (optional, but good practice)
+				if !strings.HasSuffix(strings.ToLower(part.FileName()), ".jpg") {
+					return nil, badRequest(errors.New("only .jpg files are allowed"))
+				}
+				req.Image, err = receiveImage(part, limits)
+				if err != nil {
+					return nil, err
+				}
+			}
+			part.Close()
 		}
 
-		req.Image = imageData
-
 	} else { // If not multipart/form-data (for testing, or if you want to support other formats)
 		// parse form
 		err := r.ParseForm()
 		if err != nil {
-			return nil, fmt.Errorf("failed to parse form: %w", err)
+			return nil, badRequest(fmt.Errorf("failed to parse form: %w", err))
 		}
 
 		// set form values
@@ -149,26 +310,32 @@ func parseAddItemRequest(r *http.Request) (*AddItemRequest, error) {
 		if imagePath := r.FormValue("image"); imagePath != "" {
 			// test case
 			if !strings.HasSuffix(strings.ToLower(imagePath), ".jpg") {
-				return nil, errors.New("only .jpg files are allowed")
+				return nil, badRequest(errors.New("only .jpg files are allowed"))
 			}
 
-			imageData, err := os.ReadFile(imagePath)
+			// the path is given by the client, so a file which can't be opened is an invalid request
+			f, err := os.Open(imagePath)
 			if err != nil {
-				return nil, fmt.Errorf("failed to read image file: %w", err)
+				slog.WarnContext(r.Context(), "failed to open image file: ", "error", err)
+				return nil, validationError([]FieldError{{Field: "image", Message: "must be a readable .jpg file"}})
 			}
-			if len(imageData) == 0 {
-				return nil, errors.New("image data is empty")
+			defer f.Close()
+
+			req.Image, err = receiveImage(f, limits)
+			if err != nil {
+				return nil, err
 			}
-			req.Image = imageData
 		}
 	}
-	slog.Debug("parseAddItemRequest", "name", req.Name, "category", req.Category, "image_len", len(req.Image))
+	slog.DebugContext(r.Context(), "parseAddItemRequest", "name", req.Name, "category", req.Category, "image", req.Image)
 	// Validate the request (these checks should be done regardless of Content-Type)
-	if req.Name == "" {
-		return nil, errors.New("name is required")
+	violations := validateStruct(req)
+	// an image must be uploaded with multipart/form-data, while the other format falls back to the default image
+	if isMultipart && req.Image == nil {
+		violations = append(violations, FieldError{Field: "image", Message: "is required"})
 	}
-	if req.Category == "" {
-		return nil, errors.New("category is required")
+	if len(violations) > 0 {
+		return nil, validationError(violations)
 	}
 
 	return req, nil
@@ -178,25 +345,50 @@ func parseAddItemRequest(r *http.Request) (*AddItemRequest, error) {
 func (s *Handlers) AddItem(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
-	req, err := parseAddItemRequest(r)
+	req, err := parseAddItemRequest(r, s.uploadLimits())
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		// the errors of the client are APIErrors or mapped to 400 and 413 by writeError,
+		// and any other error, e.g. failing to create a temporary file, is a 500 which doesn't tell the cause
+		if toAPIError(err).Status >= http.StatusInternalServerError {
+			slog.ErrorContext(ctx, "failed to parse add item request: ", "error", err)
+		}
+		writeError(w, r, err)
 		return
 	}
-	//get category id
-	/*_, err = s.itemRepo.GetCategoryID(ctx, req.Category)
-	  if err != nil {
-	      slog.Error("ailed to get category id", "error", err)
-	      http.Error(w, err.Error(), http.StatusInternalServerError)
-	      return
-	  }*/
+	defer req.Image.Remove()
+	if req.Image != nil {
+		s.metrics.addUploadedBytes(req.Image.Size)
+	}
+
 	// set default image name
 	fileName := "default.jpg"
-	if len(req.Image) > 0 {
-		fileName, err = s.storeImage(req.Image)
+	var similar []SimilarItem
+	if req.Image != nil {
+		info, err := s.storeImage(req.Image)
 		if err != nil {
-			slog.Error("failed to store image: ", "error", err)
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			if !errors.Is(err, errInvalidImage) {
+				slog.ErrorContext(ctx, "failed to store image: ", "error", err)
+			}
+			writeError(w, r, err)
+			return
+		}
+		fileName = info.Name
+
+		// look for similar images before the new item is inserted so that it doesn't match itself
+		if s.warnSimilar {
+			hashes, err := s.itemRepo.ListImageHashes(ctx)
+			if err != nil {
+				slog.ErrorContext(ctx, "failed to list image hashes: ", "error", err)
+				writeError(w, r, err)
+				return
+			}
+			similar = findSimilarItems(hashes, info.PHash, s.similarThreshold, 0, s.itemURLs(r))
+		}
+
+		err = s.itemRepo.SaveImage(ctx, info)
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to save image: ", "error", err)
+			writeError(w, r, err)
 			return
 		}
 	}
@@ -209,65 +401,132 @@ func (s *Handlers) AddItem(w http.ResponseWriter, r *http.Request) {
 
 	err = s.itemRepo.Insert(ctx, item)
 	if err != nil {
-		slog.Error("failed to store item: ", "error", err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		slog.ErrorContext(ctx, "failed to store item: ", "error", err)
+		writeError(w, r, err)
 		return
 	}
 
 	// Return the list of all items including the newly added item
 	items, err := s.itemRepo.List(ctx)
 	if err != nil {
-		slog.Error("failed to get items: ", "error", err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		slog.ErrorContext(ctx, "failed to get items: ", "error", err)
+		writeError(w, r, err)
 		return
 	}
 
-	resp := GetItemsResponse{Items: items}
+	resp := AddItemResponse{Items: newItemResources(items, s.itemURLs(r), nil), SimilarItems: similar}
+	if len(similar) > 0 {
+		resp.Message = "the image looks similar to existing items"
+	}
 	err = json.NewEncoder(w).Encode(resp)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
 }
 
-// storeImage stores an image and returns the file path and an error if any.
+// uploadLimits returns the upload limits of the handlers, falling back to the defaults.
+func (s *Handlers) uploadLimits() uploadLimits {
+	limits := defaultUploadLimits
+	if s.maxImageBytes > 0 {
+		limits.maxBytes = s.maxImageBytes
+	}
+	if s.maxImageDimension > 0 {
+		limits.maxDimension = s.maxImageDimension
+	}
+	return limits
+}
+
+// storeImage stores an image and returns its information and an error if any.
+// The image is re-encoded first so that metadata such as EXIF/GPS is not served to other users.
 // This method calculates the hash sum of the image as a file name to avoid the duplication of a same file
-// and stores it in the image directory.
+// and stores it in the image directory. The perceptual hash is calculated as well to find similar images.
 // func (s *Handlers) storeImage(image []byte) (filePath string, err error) {
 
-func (s *Handlers) storeImage(image []byte) (string, error) {
-	// Calculate SHA-256 hash
+func (s *Handlers) storeImage(image *UploadedImage) (*ImageInfo, error) {
+	src, err := os.Open(image.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open uploaded image: %w", err)
+	}
+	defer src.Close()
+
+	return storeImage(s.imgDirPath, src)
+}
+
+// storeImage re-encodes the JPEG image read from src and stores it in imgDirPath named by its hash.
+// It is also used to import images from outside of the API.
+func storeImage(imgDirPath string, src io.Reader) (*ImageInfo, error) {
+	// Ensure the image directory exists
+	if err := os.MkdirAll(imgDirPath, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create image directory: %w", err)
+	}
+
+	// Write the image to a temporary file in the same directory so that it can be renamed atomically
+	tmp, err := os.CreateTemp(imgDirPath, ".upload-*.jpg")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	// Strip metadata and calculate SHA-256 hash at the same time
 	hasher := sha256.New()
-	_, err := hasher.Write(image)
+	img, err := sanitizeImage(io.MultiWriter(tmp, hasher), src)
 	if err != nil {
-		return "", fmt.Errorf("failed to calculate hash: %w", err)
+		return nil, fmt.Errorf("failed to sanitize image: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return nil, fmt.Errorf("failed to save image: %w", err)
+	}
+	stat, err := os.Stat(tmp.Name())
+	if err != nil {
+		return nil, fmt.Errorf("failed to save image: %w", err)
 	}
 	hashSum := hex.EncodeToString(hasher.Sum(nil))
 	fileName := hashSum + ".jpg"
 
+	// compute the metadata now so that it doesn't have to be decoded on every request
+	info := &ImageInfo{
+		Name:           fileName,
+		Format:         "jpeg",
+		Width:          img.Bounds().Dx(),
+		Height:         img.Bounds().Dy(),
+		Size:           stat.Size(),
+		SHA256:         hashSum,
+		DominantColors: dominantColors(img, numDominantColors),
+		PHash:          perceptualHash(img),
+	}
+
 	// Create image file path
-	filePath := filepath.Join(s.imgDirPath, fileName)
+	filePath := filepath.Join(imgDirPath, fileName)
 
 	// Skip if image with same hash already exists
 	_, statErr := os.Stat(filePath)
 	if statErr == nil {
-		return fileName, nil
-	}
-
-	// Ensure the image directory exists
-	if err := os.MkdirAll(s.imgDirPath, 0755); err != nil {
-		return "", fmt.Errorf("failed to create image directory: %w", err)
+		return info, nil
 	}
 
 	// Save image
-	err = os.WriteFile(filePath, image, 0644)
-	if err != nil {
-		return "", fmt.Errorf("failed to save image: %w", err)
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return nil, fmt.Errorf("failed to save image: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), filePath); err != nil {
+		return nil, fmt.Errorf("failed to save image: %w", err)
 	}
 
-	return fileName, nil
+	return info, nil
 }
 
+const (
+	// immutableCacheControl is used for images named by their hash, whose content never changes.
+	immutableCacheControl = "public, max-age=31536000, immutable"
+	// shortCacheControl is used for other images including the default image.
+	shortCacheControl = "public, max-age=60"
+)
+
+// hashedImageName matches the file names given by storeImage.
+var hashedImageName = regexp.MustCompile(`^[0-9a-f]{64}\.jpg$`)
+
 type GetImageRequest struct {
 	FileName string // path value
 }
@@ -291,33 +550,108 @@ func parseGetImageRequest(r *http.Request) (*GetImageRequest, error) {
 func (s *Handlers) GetImage(w http.ResponseWriter, r *http.Request) {
 	req, err := parseGetImageRequest(r)
 	if err != nil {
-		slog.Warn("failed to parse get image request: ", "error", err)
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		slog.WarnContext(r.Context(), "failed to parse get image request: ", "error", err)
+		writeError(w, r, badRequest(err))
 		return
 	}
 
 	imgPath, err := s.buildImagePath(req.FileName)
 	if err != nil {
 		if !errors.Is(err, errImageNotFound) {
-			slog.Warn("failed to build image path: ", "error", err)
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			slog.WarnContext(r.Context(), "failed to build image path: ", "error", err)
+			writeError(w, r, badRequest(err))
 			return
 		}
 		// when the image is not found, it returns the default image without an error.
-		slog.Debug("image not found", "filename", imgPath)
-		imgPath = filepath.Join(s.imgDirPath, "default.jpg")
+		slog.DebugContext(r.Context(), "image not found", "filename", imgPath)
+		s.serveDefaultImage(w, r)
+		return
+	}
+
+	// hash-named images never change, so clients can cache them forever
+	if hashedImageName.MatchString(req.FileName) {
+		etag := `"` + strings.TrimSuffix(req.FileName, ".jpg") + `"`
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Cache-Control", immutableCacheControl)
+		s.metrics.observeImageCache(etagMatches(r.Header.Get("If-None-Match"), etag))
+	} else {
+		w.Header().Set("Cache-Control", shortCacheControl)
+		s.metrics.observeImageCache(false)
 	}
 
-	slog.Info("returned image", "path", imgPath)
+	slog.InfoContext(r.Context(), "returned image", "path", imgPath)
+	// http.ServeFile answers If-None-Match with 304 Not Modified using the ETag set above
 	http.ServeFile(w, r, imgPath)
 }
 
+// etagMatches reports whether an If-None-Match header matches etag, i.e. the client already has the image.
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// serveDefaultImage returns the default image in place of a missing one.
+// It is only cached for a short time so that the missing image can appear later.
+func (s *Handlers) serveDefaultImage(w http.ResponseWriter, r *http.Request) {
+	imgPath := filepath.Join(s.imgDirPath, "default.jpg")
+	f, err := os.Open(imgPath)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to open default image: ", "error", err)
+		writeError(w, r, errImageNotFound)
+		return
+	}
+	defer f.Close()
+
+	w.Header().Set("Cache-Control", shortCacheControl)
+	slog.InfoContext(r.Context(), "returned image", "path", imgPath)
+	// a zero modtime omits Last-Modified, so a later conditional request can't get a stale 304
+	http.ServeContent(w, r, imgPath, time.Time{}, f)
+}
+
+// GetImageMeta is a handler to return the metadata of an image for GET /images/{filename}/meta .
+// Unlike GetImage, it doesn't fall back to the default image.
+func (s *Handlers) GetImageMeta(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	req, err := parseGetImageRequest(r)
+	if err != nil {
+		slog.WarnContext(ctx, "failed to parse get image meta request: ", "error", err)
+		writeError(w, r, badRequest(err))
+		return
+	}
+
+	info, err := s.itemRepo.GetImageInfo(ctx, req.FileName)
+	if err != nil {
+		if !errors.Is(err, errImageNotFound) {
+			slog.ErrorContext(ctx, "failed to get image info: ", "error", err)
+		}
+		writeError(w, r, err)
+		return
+	}
+
+	err = json.NewEncoder(w).Encode(info)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+}
+
 // buildImagePath builds the image path and validates it.
 func (s *Handlers) buildImagePath(imageFileName string) (string, error) {
-	imgPath := filepath.Join(s.imgDirPath, filepath.Clean(imageFileName))
+	return buildImagePath(s.imgDirPath, imageFileName)
+}
+
+// buildImagePath builds the path of an image in imgDirPath and validates it.
+func buildImagePath(imgDirPath, imageFileName string) (string, error) {
+	imgPath := filepath.Join(imgDirPath, filepath.Clean(imageFileName))
 
 	// to prevent directory traversal attacks
-	rel, err := filepath.Rel(s.imgDirPath, imgPath)
+	rel, err := filepath.Rel(imgDirPath, imgPath)
 	if err != nil || strings.HasPrefix(rel, "..") {
 		return "", fmt.Errorf("invalid image path: %s", imgPath)
 	}
@@ -336,112 +670,152 @@ func (s *Handlers) buildImagePath(imageFileName string) (string, error) {
 	return imgPath, nil
 }
 
+type GetItemsRequest struct {
+	Category string `query:"category" validate:"trim,nfc,max=50,charset=text"` // only the items in this category if set
+	Fields   string `query:"fields"`                                           // comma-separated fields of ItemResource to return, or all if empty
+	fields   fieldSet
+}
+
 // GetItemsResponse represents the response format for the list of items
 type GetItemsResponse struct {
-	Items []Item `json:"items"`
+	Items []ItemResource `json:"items"`
+}
+
+// parseGetItemsRequest parses and validates the request to get the list of items.
+func parseGetItemsRequest(r *http.Request) (*GetItemsRequest, error) {
+	req := &GetItemsRequest{
+		Category: r.URL.Query().Get("category"),
+		Fields:   r.URL.Query().Get("fields"),
+	}
+
+	violations := validateStruct(req)
+	var err error
+	if req.fields, err = parseFieldSet(req.Fields, itemFields); err != nil {
+		violations = append(violations, FieldError{Field: "fields", Message: err.Error()})
+	}
+	if len(violations) > 0 {
+		return nil, validationError(violations)
+	}
+
+	return req, nil
 }
 
 // GetItems is a handler to return a list of items for GET /items .
 func (s *Handlers) GetItems(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
-	items, err := s.itemRepo.List(ctx)
+	req, err := parseGetItemsRequest(r)
 	if err != nil {
-		slog.Error("failed to get items: ", "error", err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		slog.WarnContext(ctx, "failed to parse get items request: ", "error", err)
+		writeError(w, r, badRequest(err))
 		return
 	}
 
-	resp := GetItemsResponse{Items: items}
+	var items []Item
+	if req.Category != "" {
+		items, err = s.itemRepo.ListByCategory(ctx, req.Category)
+	} else {
+		items, err = s.itemRepo.List(ctx)
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to get items: ", "error", err)
+		writeError(w, r, err)
+		return
+	}
+
+	resp := GetItemsResponse{Items: newItemResources(items, s.itemURLs(r), req.fields)}
 	err = json.NewEncoder(w).Encode(resp)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
 }
 
 // request format for getting item details
 type GetItemDetailRequest struct {
-	ID string // path value
+	ID     string // path value
+	Fields string `query:"fields"` // comma-separated fields of ItemResource to return, or all if empty
+	fields fieldSet
 }
 
 // parses and validates the request to get an item detail.
 func parseGetItemDetailRequest(r *http.Request) (*GetItemDetailRequest, error) {
 	req := &GetItemDetailRequest{
-		ID: r.PathValue("id"), // from path parameter
+		ID:     r.PathValue("id"), // from path parameter
+		Fields: r.URL.Query().Get("fields"),
 	}
 
 	// validate the request
 	if req.ID == "" {
 		return nil, errors.New("item id is required")
 	}
+	var err error
+	if req.fields, err = parseFieldSet(req.Fields, itemFields); err != nil {
+		return nil, validationError([]FieldError{{Field: "fields", Message: err.Error()}})
+	}
 
 	return req, nil
 }
 
-// GetItemDetailResponse defines the response format for item details
-type GetItemDetailResponse struct {
-	Name      string `json:"name"`
-	Category  string `json:"category"`
-	ImageName string `json:"image_name"`
-}
-
 // GetItemDetail is a handler to return a specific item for GET /items/{id} .
 func (s *Handlers) GetItemDetail(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
 	req, err := parseGetItemDetailRequest(r)
 	if err != nil {
-		slog.Warn("failed to parse get item detail request: ", "error", err)
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		slog.WarnContext(ctx, "failed to parse get item detail request: ", "error", err)
+		writeError(w, r, badRequest(err))
 		return
 	}
 
 	// Get item details
 	item, err := s.itemRepo.Get(ctx, req.ID)
 	if err != nil {
-		if errors.Is(err, errItemNotFound) {
-			http.Error(w, "item not found", http.StatusNotFound)
-			return
+		if !errors.Is(err, errItemNotFound) {
+			slog.ErrorContext(ctx, "failed to get item: ", "error", err)
 		}
-		slog.Error("failed to get item: ", "error", err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
 
 	// Convert to response format
-	resp := GetItemDetailResponse{
-		Name:      item.Name,
-		Category:  item.Category,
-		ImageName: item.ImageName,
-	}
+	resp := newItemResource(*item, s.itemURLs(r), req.fields)
 
 	err = json.NewEncoder(w).Encode(resp)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
 }
 
 type SearchItemsRequest struct {
-	Keyword string // query value
+	Keyword string `query:"keyword" validate:"trim,nfc,required,max=100,charset=text"`
+	Fields  string `query:"fields"` // comma-separated fields of ItemResource to return, or all if empty
+	fields  fieldSet
 }
 
 // response format for search items
 type SearchItemsResponse struct {
-	Items []GetItemDetailResponse `json:"items"`
+	Items []ItemResource `json:"items"`
 }
 
 // get the keyword from the request
 func parseSearchItemsRequest(r *http.Request) (*SearchItemsRequest, error) {
-	keyword := r.URL.Query().Get("keyword")
-	if keyword == "" {
-		return nil, errors.New("keyword is required")
+	req := &SearchItemsRequest{
+		Keyword: r.URL.Query().Get("keyword"),
+		Fields:  r.URL.Query().Get("fields"),
 	}
 
-	return &SearchItemsRequest{
-		Keyword: keyword,
-	}, nil
+	violations := validateStruct(req)
+	var err error
+	if req.fields, err = parseFieldSet(req.Fields, itemFields); err != nil {
+		violations = append(violations, FieldError{Field: "fields", Message: err.Error()})
+	}
+	if len(violations) > 0 {
+		return nil, validationError(violations)
+	}
+
+	return req, nil
 }
 
 // Search returns a list of items containing the given keyword
@@ -450,37 +824,148 @@ func (s *Handlers) Search(w http.ResponseWriter, r *http.Request) {
 
 	req, err := parseSearchItemsRequest(r)
 	if err != nil {
-		slog.Warn("failed to parse search items request: ", "error", err)
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		slog.WarnContext(ctx, "failed to parse search items request: ", "error", err)
+		writeError(w, r, badRequest(err))
 		return
 	}
 
 	// search items containing the given keyword
 	items, err := s.itemRepo.Search(ctx, req.Keyword)
 	if err != nil {
-		slog.Error("failed to search items: ", "error", err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		slog.ErrorContext(ctx, "failed to search items: ", "error", err)
+		writeError(w, r, err)
 		return
 	}
 
-	// convert items to response format
-	var respItems []GetItemDetailResponse
-	for _, item := range items {
-		respItems = append(respItems, GetItemDetailResponse{
-			Name:      item.Name,
-			Category:  item.Category,
-			ImageName: item.ImageName,
-		})
-	}
-
 	// return the list of items containing the given keyword
 	resp := SearchItemsResponse{
-		Items: respItems,
+		Items: newItemResources(items, s.itemURLs(r), req.fields),
+	}
+
+	err = json.NewEncoder(w).Encode(resp)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+}
+
+// defaultSimilarImageThreshold is the default maximum Hamming distance of similar images.
+// Recompressed or slightly cropped copies of a photo are usually within this distance.
+const defaultSimilarImageThreshold = 10
+
+// SimilarItem is an item whose image looks like another image.
+type SimilarItem struct {
+	ItemResource
+	// Distance is the Hamming distance between the perceptual hashes. Zero means they look identical.
+	Distance int `json:"distance"`
+}
+
+// MarshalJSON encodes the item resource with the distance, which the promoted method of ItemResource would drop.
+func (s SimilarItem) MarshalJSON() ([]byte, error) {
+	b, err := s.ItemResource.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	b = bytes.TrimSuffix(b, []byte("}"))
+	if len(b) > 1 {
+		b = append(b, ',')
+	}
+	return fmt.Appendf(b, `"distance":%d}`, s.Distance), nil
+}
+
+// findSimilarItems returns the items in hashes whose image is within maxDistance of the given
+// perceptual hash, closest first. The item with excludeID is not included.
+func findSimilarItems(hashes []ItemImageHash, phash uint64, maxDistance int, excludeID int, urls itemURLs) []SimilarItem {
+	similar := []SimilarItem{}
+	for _, h := range hashes {
+		if h.ID == excludeID {
+			continue
+		}
+		if d := hammingDistance(phash, h.PHash); d <= maxDistance {
+			similar = append(similar, SimilarItem{ItemResource: newItemResource(h.Item, urls, nil), Distance: d})
+		}
+	}
+	sort.SliceStable(similar, func(i, j int) bool {
+		if similar[i].Distance != similar[j].Distance {
+			return similar[i].Distance < similar[j].Distance
+		}
+		return similar[i].ID < similar[j].ID
+	})
+	return similar
+}
+
+type GetSimilarImagesRequest struct {
+	ID          string // path value
+	MaxDistance int    `query:"max_distance"`
+}
+
+// GetSimilarImagesResponse is the response format for similar images
+type GetSimilarImagesResponse struct {
+	Items []SimilarItem `json:"items"`
+}
+
+// parseGetSimilarImagesRequest parses and validates the request to get similar images.
+func parseGetSimilarImagesRequest(r *http.Request, defaultMaxDistance int) (*GetSimilarImagesRequest, error) {
+	req := &GetSimilarImagesRequest{
+		ID:          r.PathValue("id"), // from path parameter
+		MaxDistance: defaultMaxDistance,
+	}
+
+	// validate the request
+	if req.ID == "" {
+		return nil, errors.New("item id is required")
+	}
+	if v := r.URL.Query().Get("max_distance"); v != "" {
+		d, err := strconv.Atoi(v)
+		if err != nil || d < 0 || d > 64 {
+			return nil, errors.New("max_distance must be an integer between 0 and 64")
+		}
+		req.MaxDistance = d
+	}
+
+	return req, nil
+}
+
+// GetSimilarImages is a handler to return items whose image looks like the image of
+// a specific item for GET /items/{id}/similar-images .
+func (s *Handlers) GetSimilarImages(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	req, err := parseGetSimilarImagesRequest(r, s.similarThreshold)
+	if err != nil {
+		slog.WarnContext(ctx, "failed to parse get similar images request: ", "error", err)
+		writeError(w, r, badRequest(err))
+		return
+	}
+
+	item, err := s.itemRepo.Get(ctx, req.ID)
+	if err != nil {
+		if !errors.Is(err, errItemNotFound) {
+			slog.ErrorContext(ctx, "failed to get item: ", "error", err)
+		}
+		writeError(w, r, err)
+		return
+	}
+
+	hashes, err := s.itemRepo.ListImageHashes(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to list image hashes: ", "error", err)
+		writeError(w, r, err)
+		return
+	}
+
+	// items without a stored image (e.g. default.jpg) have no perceptual hash and no similar images
+	resp := GetSimilarImagesResponse{Items: []SimilarItem{}}
+	for _, h := range hashes {
+		if h.ID == item.ID {
+			resp.Items = findSimilarItems(hashes, h.PHash, req.MaxDistance, item.ID, s.itemURLs(r))
+			break
+		}
 	}
 
 	err = json.NewEncoder(w).Encode(resp)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
 }
@@ -1,13 +1,22 @@
 package app
 
 import (
+	"bytes"
+	"image/jpeg"
+	"io"
+	"mime/multipart"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"path/filepath"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 	"os"
 	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
 	"go.uber.org/mock/gomock"
 	"encoding/json"
 	"errors"
@@ -25,15 +34,21 @@ func TestParseAddItemRequest(t *testing.T) {
 	if err != nil {
 		t.Fatalf("failed to read image file: %v", err)
 	}
+	imageConfig, err := jpeg.DecodeConfig(bytes.NewReader(imageBytes))
+	if err != nil {
+		t.Fatalf("failed to decode image file: %v", err)
+	}
 
 	type wants struct {
-		req *AddItemRequest
-		err bool
+		req  *AddItemRequest
+		err  bool
+		code int // status of the error
 	}
 
 	// STEP 6-1: define test cases
 	cases := map[string]struct {
-		args map[string]string
+		args   map[string]string
+		limits uploadLimits
 		wants
 	}{
 		"ok: valid request": {
@@ -42,20 +57,104 @@ func TestParseAddItemRequest(t *testing.T) {
 				"category": "fashion_test",
 				"image":    "../images/default.jpg",
 			},
+			limits: defaultUploadLimits,
 			wants: wants{
 				req: &AddItemRequest{
 					Name:     "jaket_test",
 					Category: "fashion_test",
-					Image:    imageBytes,
+					Image: &UploadedImage{
+						Size: int64(len(imageBytes)),
+					},
+				},
+				err: false,
+			},
+		},
+		"ok: values are trimmed and normalized": {
+			args: map[string]string{
+				"name":     "  cafe\u0301 mug ",
+				"category": "kitchen\t",
+			},
+			limits: defaultUploadLimits,
+			wants: wants{
+				req: &AddItemRequest{
+					Name:     "caf\u00e9 mug",
+					Category: "kitchen",
 				},
 				err: false,
 			},
 		},
+		"ng: blank name": {
+			args: map[string]string{
+				"name":     "   ",
+				"category": "fashion_test",
+			},
+			limits: defaultUploadLimits,
+			wants: wants{
+				req: nil,
+				err:  true,
+				code: http.StatusUnprocessableEntity,
+			},
+		},
 		"ng: empty request": {
-			args: map[string]string{},
+			args:   map[string]string{},
+			limits: defaultUploadLimits,
+			wants: wants{
+				req: nil,
+				err:  true,
+				code: http.StatusUnprocessableEntity,
+			},
+		},
+		"ng: image too large": {
+			args: map[string]string{
+				"name":     "jaket_test",
+				"category": "fashion_test",
+				"image":    "../images/default.jpg",
+			},
+			limits: uploadLimits{maxBytes: int64(len(imageBytes)) - 1, maxDimension: 8000},
+			wants: wants{
+				req: nil,
+				err:  true,
+				code: http.StatusRequestEntityTooLarge,
+			},
+		},
+		"ng: image dimensions too large": {
+			args: map[string]string{
+				"name":     "jaket_test",
+				"category": "fashion_test",
+				"image":    "../images/default.jpg",
+			},
+			limits: uploadLimits{maxBytes: 10 << 20, maxDimension: imageConfig.Width - 1},
 			wants: wants{
 				req: nil,
-				err: true,
+				err:  true,
+				code: http.StatusRequestEntityTooLarge,
+			},
+		},
+		"ng: not a jpg": {
+			args: map[string]string{
+				"name":     "jaket_test",
+				"category": "fashion_test",
+				"image":    "../images/default.png",
+			},
+			limits: defaultUploadLimits,
+			wants: wants{
+				req:  nil,
+				err:  true,
+				code: http.StatusBadRequest,
+			},
+		},
+		// the path is given by the client, so it is told that the image is invalid without the cause
+		"ng: image file can't be read": {
+			args: map[string]string{
+				"name":     "jaket_test",
+				"category": "fashion_test",
+				"image":    "../images/missing.jpg",
+			},
+			limits: defaultUploadLimits,
+			wants: wants{
+				req:  nil,
+				err:  true,
+				code: http.StatusUnprocessableEntity,
 			},
 		},
 	}
@@ -78,16 +177,23 @@ func TestParseAddItemRequest(t *testing.T) {
 			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
 			// execute test target
-			got, err := parseAddItemRequest(req)
+			got, err := parseAddItemRequest(req, tt.limits)
 
 			// confirm the result
 			if err != nil {
 				if !tt.err {
 					t.Errorf("unexpected error: %v", err)
 				}
+				if got := toAPIError(err).Status; got != tt.wants.code {
+					t.Errorf("expected status code %d, got %d: %v", tt.wants.code, got, err)
+				}
 				return
 			}
-			if diff := cmp.Diff(tt.wants.req, got); diff != "" {
+			t.Cleanup(func() { got.Image.Remove() })
+			if tt.err {
+				t.Errorf("expected an error, got none")
+			}
+			if diff := cmp.Diff(tt.wants.req, got, cmpopts.IgnoreFields(UploadedImage{}, "Path")); diff != "" {
 				t.Errorf("unexpected request (-want +got):\n%s", diff)
 			}
 		})
@@ -132,6 +238,16 @@ func TestHelloHandler(t *testing.T) {
 func TestAddItem(t *testing.T) {
 	t.Parallel()
 
+	// a JPEG whose header is intact passes parsing, but is rejected when the image is stored
+	imageBytes, err := os.ReadFile("../images/default.jpg")
+	if err != nil {
+		t.Fatalf("failed to read image file: %v", err)
+	}
+	truncatedImage := filepath.Join(t.TempDir(), "truncated.jpg")
+	if err := os.WriteFile(truncatedImage, imageBytes[:len(imageBytes)/2], 0644); err != nil {
+		t.Fatalf("failed to write image file: %v", err)
+	}
+
 	type wants struct {
 		code int
 	}
@@ -147,10 +263,6 @@ func TestAddItem(t *testing.T) {
 			},
 			injector: func(m *MockItemRepository) {
 				// STEP 6-3: define mock expectation
-                m.EXPECT().
-                    GetCategoryID(gomock.Any(), "phone").
-                    Return(1, nil)
-
                 m.EXPECT().
                     Insert(gomock.Any(), gomock.Any()).
                     DoAndReturn(func(ctx context.Context, item *Item) error {
@@ -169,6 +281,18 @@ func TestAddItem(t *testing.T) {
 				code: http.StatusOK,
 			},
 		},
+		// nothing is written, including the category, if the image is rejected
+		"ng: invalid image": {
+			args: map[string]string{
+				"name":     "used iPhone 16e",
+				"category": "new category",
+				"image":    truncatedImage,
+			},
+			injector: func(m *MockItemRepository) {},
+			wants: wants{
+				code: http.StatusBadRequest,
+			},
+		},
 		"ng: failed to insert": {
 			args: map[string]string{
 				"name":     "used iPhone 16e",
@@ -176,10 +300,6 @@ func TestAddItem(t *testing.T) {
 			},
 			injector: func(m *MockItemRepository) {
 				// STEP 6-3: define mock expectation
-				m.EXPECT().
-                    GetCategoryID(gomock.Any(), "phone").
-                    Return(1, nil)
-
                 m.EXPECT().
                     Insert(gomock.Any(), gomock.Any()).
                     Return(errors.New("failed to insert"))
@@ -227,7 +347,520 @@ func TestAddItem(t *testing.T) {
 	}
 }
 
+func TestAddItemImageTooLarge(t *testing.T) {
+	t.Parallel()
+
+	imageBytes, err := os.ReadFile("../images/default.jpg")
+	if err != nil {
+		t.Fatalf("failed to read image file: %v", err)
+	}
+
+	// prepare a multipart body with an image larger than the limit
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	mw.WriteField("name", "jacket")
+	mw.WriteField("category", "fashion")
+	fw, err := mw.CreateFormFile("image", "default.jpg")
+	if err != nil {
+		t.Fatalf("failed to create form file: %v", err)
+	}
+	fw.Write(imageBytes)
+	mw.Close()
+
+	ctrl := gomock.NewController(t)
+	h := &Handlers{imgDirPath: "../images", itemRepo: NewMockItemRepository(ctrl), maxImageBytes: 16}
+
+	req := httptest.NewRequest("POST", "/items", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	rr := httptest.NewRecorder()
+	h.AddItem(rr, req)
+
+	if rr.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected status code %d, got %d", http.StatusRequestEntityTooLarge, rr.Code)
+	}
+	if !strings.Contains(rr.Body.String(), "maximum size of 16 bytes") {
+		t.Errorf("unexpected response body: %s", rr.Body.String())
+	}
+}
+
+func TestGetImage(t *testing.T) {
+	t.Parallel()
+
+	imageBytes, err := os.ReadFile("../images/default.jpg")
+	if err != nil {
+		t.Fatalf("failed to read image file: %v", err)
+	}
+	hash := strings.Repeat("ab", 32)
+	missing := strings.Repeat("cd", 32)
+
+	dir := t.TempDir()
+	for _, name := range []string{"default.jpg", hash + ".jpg"} {
+		if err := os.WriteFile(filepath.Join(dir, name), imageBytes, 0644); err != nil {
+			t.Fatalf("failed to write image file: %v", err)
+		}
+	}
+
+	type wants struct {
+		code         int
+		etag         string
+		cacheControl string
+	}
+	cases := map[string]struct {
+		filename    string
+		ifNoneMatch string
+		wants
+	}{
+		"ok: hashed image": {
+			filename: hash + ".jpg",
+			wants: wants{
+				code:         http.StatusOK,
+				etag:         `"` + hash + `"`,
+				cacheControl: immutableCacheControl,
+			},
+		},
+		"ok: not modified": {
+			filename:    hash + ".jpg",
+			ifNoneMatch: `"` + hash + `"`,
+			wants: wants{
+				code:         http.StatusNotModified,
+				etag:         `"` + hash + `"`,
+				cacheControl: immutableCacheControl,
+			},
+		},
+		"ok: modified": {
+			filename:    hash + ".jpg",
+			ifNoneMatch: `"` + missing + `"`,
+			wants: wants{
+				code:         http.StatusOK,
+				etag:         `"` + hash + `"`,
+				cacheControl: immutableCacheControl,
+			},
+		},
+		"ok: missing image falls back to the default image": {
+			filename:    missing + ".jpg",
+			ifNoneMatch: `"` + missing + `"`,
+			wants: wants{
+				code:         http.StatusOK,
+				cacheControl: shortCacheControl,
+			},
+		},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			h := &Handlers{imgDirPath: dir}
+
+			req := httptest.NewRequest("GET", "/images/"+tt.filename, nil)
+			req.SetPathValue("filename", tt.filename)
+			if tt.ifNoneMatch != "" {
+				req.Header.Set("If-None-Match", tt.ifNoneMatch)
+			}
+			rr := httptest.NewRecorder()
+			h.GetImage(rr, req)
+
+			if rr.Code != tt.wants.code {
+				t.Errorf("expected status code %d, got %d", tt.wants.code, rr.Code)
+			}
+			if got := rr.Header().Get("ETag"); got != tt.wants.etag {
+				t.Errorf("expected ETag %q, got %q", tt.wants.etag, got)
+			}
+			if got := rr.Header().Get("Cache-Control"); got != tt.wants.cacheControl {
+				t.Errorf("expected Cache-Control %q, got %q", tt.wants.cacheControl, got)
+			}
+			if tt.wants.code == http.StatusOK && !bytes.Equal(rr.Body.Bytes(), imageBytes) {
+				t.Errorf("unexpected response body")
+			}
+		})
+	}
+}
+
+func TestGetSimilarImages(t *testing.T) {
+	t.Parallel()
+
+	hashes := []ItemImageHash{
+		{Item: Item{ID: 1, Name: "jacket", Category: "fashion", ImageName: "a.jpg"}, PHash: 0x00ff},
+		{Item: Item{ID: 2, Name: "jacket copy", Category: "fashion", ImageName: "b.jpg"}, PHash: 0x00fe},
+		{Item: Item{ID: 3, Name: "same jacket", Category: "fashion", ImageName: "a.jpg"}, PHash: 0x00ff},
+		{Item: Item{ID: 4, Name: "boots", Category: "fashion", ImageName: "c.jpg"}, PHash: 0xff00},
+	}
+
+	type wants struct {
+		code int
+		ids  []int
+	}
+	cases := map[string]struct {
+		id        string
+		query     string
+		threshold *int // configured threshold, the default if nil
+		injector  func(m *MockItemRepository)
+		wants
+	}{
+		"ok: similar images": {
+			id: "1",
+			injector: func(m *MockItemRepository) {
+				m.EXPECT().Get(gomock.Any(), "1").Return(&hashes[0].Item, nil)
+				m.EXPECT().ListImageHashes(gomock.Any()).Return(hashes, nil)
+			},
+			wants: wants{code: http.StatusOK, ids: []int{3, 2}},
+		},
+		"ok: threshold of zero is configured": {
+			id:        "1",
+			threshold: new(int),
+			injector: func(m *MockItemRepository) {
+				m.EXPECT().Get(gomock.Any(), "1").Return(&hashes[0].Item, nil)
+				m.EXPECT().ListImageHashes(gomock.Any()).Return(hashes, nil)
+			},
+			wants: wants{code: http.StatusOK, ids: []int{3}},
+		},
+		"ok: exact matches only": {
+			id:    "1",
+			query: "?max_distance=0",
+			injector: func(m *MockItemRepository) {
+				m.EXPECT().Get(gomock.Any(), "1").Return(&hashes[0].Item, nil)
+				m.EXPECT().ListImageHashes(gomock.Any()).Return(hashes, nil)
+			},
+			wants: wants{code: http.StatusOK, ids: []int{3}},
+		},
+		"ok: item without a perceptual hash": {
+			id: "5",
+			injector: func(m *MockItemRepository) {
+				m.EXPECT().Get(gomock.Any(), "5").Return(&Item{ID: 5, ImageName: "default.jpg"}, nil)
+				m.EXPECT().ListImageHashes(gomock.Any()).Return(hashes, nil)
+			},
+			wants: wants{code: http.StatusOK, ids: []int{}},
+		},
+		"ng: item not found": {
+			id: "6",
+			injector: func(m *MockItemRepository) {
+				m.EXPECT().Get(gomock.Any(), "6").Return(nil, errItemNotFound)
+			},
+			wants: wants{code: http.StatusNotFound},
+		},
+		"ng: invalid max_distance": {
+			id:       "1",
+			query:    "?max_distance=65",
+			injector: func(m *MockItemRepository) {},
+			wants:    wants{code: http.StatusBadRequest},
+		},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ctrl := gomock.NewController(t)
+			mockIR := NewMockItemRepository(ctrl)
+			tt.injector(mockIR)
+			h := &Handlers{itemRepo: mockIR, similarThreshold: defaultSimilarImageThreshold}
+			if tt.threshold != nil {
+				h.similarThreshold = *tt.threshold
+			}
+
+			req := httptest.NewRequest("GET", "/items/"+tt.id+"/similar-images"+tt.query, nil)
+			req.SetPathValue("id", tt.id)
+			rr := httptest.NewRecorder()
+			h.GetSimilarImages(rr, req)
+
+			if rr.Code != tt.wants.code {
+				t.Errorf("expected status code %d, got %d", tt.wants.code, rr.Code)
+			}
+			if tt.wants.code >= 400 {
+				return
+			}
+
+			var resp GetSimilarImagesResponse
+			if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("failed to unmarshal response body: %v", err)
+			}
+			ids := []int{}
+			for _, item := range resp.Items {
+				ids = append(ids, item.ID)
+			}
+			if diff := cmp.Diff(tt.wants.ids, ids); diff != "" {
+				t.Errorf("unexpected items (-want +got):\n%s", diff)
+			}
+			// similar items are represented like the items of the other endpoints
+			for _, item := range resp.Items {
+				if item.ImageURL == "" || item.Links.Self == "" {
+					t.Errorf("expected item %d to have an image URL and links, got %+v", item.ID, item.ItemResource)
+				}
+			}
+		})
+	}
+}
+
+func TestGetImageMeta(t *testing.T) {
+	t.Parallel()
+
+	info := &ImageInfo{
+		Name:           "abc.jpg",
+		Format:         "jpeg",
+		Width:          640,
+		Height:         480,
+		Size:           12345,
+		SHA256:         "abc",
+		DominantColors: []string{"#ffffff", "#000000"},
+	}
+
+	type wants struct {
+		code int
+		body *ImageInfo
+	}
+	cases := map[string]struct {
+		filename string
+		injector func(m *MockItemRepository)
+		wants
+	}{
+		"ok: stored image": {
+			filename: "abc.jpg",
+			injector: func(m *MockItemRepository) {
+				m.EXPECT().GetImageInfo(gomock.Any(), "abc.jpg").Return(info, nil)
+			},
+			wants: wants{code: http.StatusOK, body: info},
+		},
+		"ng: unknown image": {
+			filename: "missing.jpg",
+			injector: func(m *MockItemRepository) {
+				m.EXPECT().GetImageInfo(gomock.Any(), "missing.jpg").Return(nil, errImageNotFound)
+			},
+			wants: wants{code: http.StatusNotFound},
+		},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ctrl := gomock.NewController(t)
+			mockIR := NewMockItemRepository(ctrl)
+			tt.injector(mockIR)
+			h := &Handlers{itemRepo: mockIR}
+
+			req := httptest.NewRequest("GET", "/images/"+tt.filename+"/meta", nil)
+			req.SetPathValue("filename", tt.filename)
+			rr := httptest.NewRecorder()
+			h.GetImageMeta(rr, req)
+
+			if rr.Code != tt.wants.code {
+				t.Errorf("expected status code %d, got %d", tt.wants.code, rr.Code)
+			}
+			if tt.wants.code >= 400 {
+				return
+			}
+
+			var got ImageInfo
+			if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+				t.Fatalf("failed to unmarshal response body: %v", err)
+			}
+			if diff := cmp.Diff(tt.wants.body, &got); diff != "" {
+				t.Errorf("unexpected response body (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSearch(t *testing.T) {
+	t.Parallel()
+
+	items := []Item{{ID: 1, Name: "jacket", Category: "fashion", ImageName: "default.jpg"}}
+
+	type wants struct {
+		code int
+		body string
+	}
+	cases := map[string]struct {
+		query    string
+		injector func(m *MockItemRepository)
+		wants
+	}{
+		"ok: items with their id and links": {
+			query: "?keyword=jack",
+			injector: func(m *MockItemRepository) {
+				m.EXPECT().Search(gomock.Any(), "jack").Return(items, nil)
+			},
+			wants: wants{
+				code: http.StatusOK,
+				body: `{"items":[{"id":1,"name":"jacket","category":"fashion","image_name":"default.jpg","image_url":"http://example.com/v1/images/default.jpg",` +
+					`"links":{"self":"http://example.com/v1/items/1","category":"http://example.com/v1/items?category=fashion"}}]}`,
+			},
+		},
+		"ok: selected fields": {
+			query: "?keyword=jack&fields=id,name",
+			injector: func(m *MockItemRepository) {
+				m.EXPECT().Search(gomock.Any(), "jack").Return(items, nil)
+			},
+			wants: wants{code: http.StatusOK, body: `{"items":[{"id":1,"name":"jacket"}]}`},
+		},
+		"ok: no items": {
+			query: "?keyword=shoes",
+			injector: func(m *MockItemRepository) {
+				m.EXPECT().Search(gomock.Any(), "shoes").Return(nil, nil)
+			},
+			wants: wants{code: http.StatusOK, body: `{"items":[]}`},
+		},
+		"ng: unknown field": {
+			query:    "?keyword=jack&fields=price",
+			injector: func(m *MockItemRepository) {},
+			wants:    wants{code: http.StatusUnprocessableEntity},
+		},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ctrl := gomock.NewController(t)
+			mockIR := NewMockItemRepository(ctrl)
+			tt.injector(mockIR)
+			h := &Handlers{itemRepo: mockIR}
+
+			req := httptest.NewRequest("GET", "/search"+tt.query, nil)
+			rr := httptest.NewRecorder()
+			h.Search(rr, req)
+
+			if rr.Code != tt.wants.code {
+				t.Errorf("expected status code %d, got %d", tt.wants.code, rr.Code)
+			}
+			if tt.wants.code >= 400 {
+				return
+			}
+			if got := strings.TrimSpace(rr.Body.String()); got != tt.wants.body {
+				t.Errorf("expected body %s, got %s", tt.wants.body, got)
+			}
+		})
+	}
+}
+
+func TestServe(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		timeout time.Duration
+		wantErr bool
+	}{
+		"ok: in-flight request is drained": {
+			timeout: 5 * time.Second,
+			wantErr: false,
+		},
+		"ng: in-flight request exceeds the deadline": {
+			timeout: 10 * time.Millisecond,
+			wantErr: true,
+		},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			started := make(chan struct{})
+			release := make(chan struct{})
+			srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				close(started)
+				<-release
+				w.Write([]byte("done"))
+			})}
+
+			ln, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatalf("failed to listen: %v", err)
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			serveErr := make(chan error, 1)
+			go func() {
+				serveErr <- serve(ctx, srv, ln, func() {}, 0, tt.timeout)
+			}()
+
+			type result struct {
+				body string
+				err  error
+			}
+			resCh := make(chan result, 1)
+			go func() {
+				resp, err := http.Get("http://" + ln.Addr().String())
+				if err != nil {
+					resCh <- result{err: err}
+					return
+				}
+				defer resp.Body.Close()
+				body, err := io.ReadAll(resp.Body)
+				resCh <- result{body: string(body), err: err}
+			}()
+
+			// shut down while the request is in flight
+			<-started
+			cancel()
+
+			if tt.wantErr {
+				if err := <-serveErr; err == nil {
+					t.Errorf("expected an error, got none")
+				}
+				close(release)
+				return
+			}
+
+			time.Sleep(50 * time.Millisecond)
+			close(release)
+			if res := <-resCh; res.err != nil || res.body != "done" {
+				t.Errorf("expected the in-flight request to finish, got body %q and error %v", res.body, res.err)
+			}
+			if err := <-serveErr; err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
 // STEP 6-4: uncomment this test
+func TestServeDrainDelay(t *testing.T) {
+	t.Parallel()
+
+	// the server keeps accepting connections during the drain delay, failing the readiness check
+	var draining atomic.Bool
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if draining.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	})}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	serveErr := make(chan error, 1)
+	drainStarted := make(chan struct{})
+	go func() {
+		serveErr <- serve(ctx, srv, ln, func() {
+			draining.Store(true)
+			close(drainStarted)
+		}, 200*time.Millisecond, time.Second)
+	}()
+	cancel()
+	<-drainStarted
+
+	// a new connection, as a load balancer checking /readyz makes
+	client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+	resp, err := client.Get("http://" + ln.Addr().String() + "/readyz")
+	if err != nil {
+		t.Fatalf("expected the server to accept connections while draining: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("expected status code %d while draining, got %d", http.StatusServiceUnavailable, resp.StatusCode)
+	}
+
+	if err := <-serveErr; err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, err := client.Get("http://" + ln.Addr().String() + "/readyz"); err == nil {
+		t.Errorf("expected new connections to be refused after the drain delay")
+	}
+}
+
 func TestAddItemE2e(t *testing.T) {
 	if testing.Short() {
 		t.Skip("skipping e2e test")
@@ -265,7 +898,7 @@ func TestAddItemE2e(t *testing.T) {
 				"category": "phone",
 			},
 			wants: wants{
-				code: http.StatusBadRequest,
+				code: http.StatusUnprocessableEntity,
 			},
 		},
 	}
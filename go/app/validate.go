@@ -0,0 +1,131 @@
+package app
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// This file provides declarative validation of request structs.
+// Each field lists its rules in a `validate` tag, which are applied in order:
+//
+//	trim        removes leading and trailing white space
+//	nfc         normalizes to Unicode NFC, so that e.g. "é" typed on different devices is stored the same
+//	required    must not be empty (or nil)
+//	min=N       must be at least N characters
+//	max=N       must be at most N characters
+//	charset=C   must only contain the characters of C: "text" (no control characters) or "alnum"
+//	oneof=A B   must be one of the space-separated values
+//
+// The field is named after its `form` or `query` tag in violations, so that clients can tell which input is wrong.
+
+// validateStruct normalizes the string fields of the struct pointed to by v and checks them against their rules.
+// It returns every violated field, at most one violation per field.
+// It panics on unknown rules since they are programming errors.
+func validateStruct(v any) []FieldError {
+	rv := reflect.ValueOf(v).Elem()
+	rt := rv.Type()
+
+	var violations []FieldError
+	for i := 0; i < rt.NumField(); i++ {
+		tag, ok := rt.Field(i).Tag.Lookup("validate")
+		if !ok {
+			continue
+		}
+		name := fieldName(rt.Field(i))
+		if msg := validateField(rv.Field(i), strings.Split(tag, ",")); msg != "" {
+			violations = append(violations, FieldError{Field: name, Message: msg})
+		}
+	}
+	return violations
+}
+
+// validateField applies rules to a field and returns the message of the first violation, or "".
+func validateField(field reflect.Value, rules []string) string {
+	for _, rule := range rules {
+		rule, arg, _ := strings.Cut(rule, "=")
+
+		switch rule {
+		case "trim":
+			field.SetString(strings.TrimSpace(field.String()))
+		case "nfc":
+			field.SetString(norm.NFC.String(field.String()))
+		case "required":
+			if field.IsZero() {
+				return "is required"
+			}
+		case "min":
+			if utf8.RuneCountInString(field.String()) < mustAtoi(arg) {
+				return fmt.Sprintf("must be at least %s characters", arg)
+			}
+		case "max":
+			if utf8.RuneCountInString(field.String()) > mustAtoi(arg) {
+				return fmt.Sprintf("must be at most %s characters", arg)
+			}
+		case "charset":
+			if msg := checkCharset(field.String(), arg); msg != "" {
+				return msg
+			}
+		case "oneof":
+			allowed := strings.Fields(arg)
+			if !slices.Contains(allowed, field.String()) {
+				return fmt.Sprintf("must be one of: %s", strings.Join(allowed, ", "))
+			}
+		default:
+			panic(fmt.Sprintf("unknown validation rule %q", rule))
+		}
+	}
+	return ""
+}
+
+// checkCharset returns a message if s contains characters outside of charset, or "".
+func checkCharset(s, charset string) string {
+	switch charset {
+	case "text":
+		if strings.ContainsFunc(s, unicode.IsControl) {
+			return "must not contain control characters"
+		}
+	case "alnum":
+		if strings.ContainsFunc(s, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) }) {
+			return "must only contain letters and digits"
+		}
+	default:
+		panic(fmt.Sprintf("unknown charset %q", charset))
+	}
+	return ""
+}
+
+// fieldName returns the name of a field as seen by clients.
+func fieldName(f reflect.StructField) string {
+	for _, key := range []string{"form", "query"} {
+		if name, ok := f.Tag.Lookup(key); ok {
+			return name
+		}
+	}
+	return strings.ToLower(f.Name)
+}
+
+func mustAtoi(s string) int {
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		panic(fmt.Sprintf("invalid validation argument %q", s))
+	}
+	return n
+}
+
+// validationError returns a 422 error reporting all violations.
+func validationError(violations []FieldError) *APIError {
+	return &APIError{
+		Status:  http.StatusUnprocessableEntity,
+		Code:    codeValidation,
+		Message: "request has invalid fields",
+		Details: violations,
+	}
+}
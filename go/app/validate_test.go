@@ -0,0 +1,101 @@
+package app
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestValidateStruct(t *testing.T) {
+	t.Parallel()
+
+	type request struct {
+		Name  string `form:"name" validate:"trim,nfc,required,min=2,max=5,charset=text"`
+		Code  string `query:"code" validate:"charset=alnum"`
+		Sort  string `query:"sort" validate:"oneof=name id"`
+		Note  string `validate:"trim"`
+		Other string
+	}
+
+	cases := map[string]struct {
+		req            request
+		wantReq        request
+		wantViolations []FieldError
+	}{
+		"ok: valid": {
+			req:     request{Name: " abc ", Code: "a1", Sort: "id", Note: " x ", Other: " y "},
+			wantReq: request{Name: "abc", Code: "a1", Sort: "id", Note: "x", Other: " y "},
+		},
+		"ok: normalized to NFC before counting": {
+			// "e" and a combining accent are a single character after normalization
+			req:     request{Name: "cafe\u0301!", Sort: "name"},
+			wantReq: request{Name: "caf\u00e9!", Sort: "name"},
+		},
+		"ng: all violations are reported": {
+			req:     request{Name: "  ", Code: "a-1", Sort: "price"},
+			wantReq: request{Name: "", Code: "a-1", Sort: "price"},
+			wantViolations: []FieldError{
+				{Field: "name", Message: "is required"},
+				{Field: "code", Message: "must only contain letters and digits"},
+				{Field: "sort", Message: "must be one of: name, id"},
+			},
+		},
+		"ng: too short": {
+			req:            request{Name: "a", Sort: "id"},
+			wantReq:        request{Name: "a", Sort: "id"},
+			wantViolations: []FieldError{{Field: "name", Message: "must be at least 2 characters"}},
+		},
+		"ng: too long": {
+			req:            request{Name: "abcdef", Sort: "id"},
+			wantReq:        request{Name: "abcdef", Sort: "id"},
+			wantViolations: []FieldError{{Field: "name", Message: "must be at most 5 characters"}},
+		},
+		"ng: control characters": {
+			req:            request{Name: "a\x00b", Sort: "id"},
+			wantReq:        request{Name: "a\x00b", Sort: "id"},
+			wantViolations: []FieldError{{Field: "name", Message: "must not contain control characters"}},
+		},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got := validateStruct(&tt.req)
+			if diff := cmp.Diff(tt.wantViolations, got); diff != "" {
+				t.Errorf("unexpected violations (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tt.wantReq, tt.req); diff != "" {
+				t.Errorf("unexpected normalized request (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestValidationErrorResponse(t *testing.T) {
+	t.Parallel()
+
+	req := httptest.NewRequest("GET", "/search?keyword=%20", nil)
+	_, err := parseSearchItemsRequest(req)
+	if err == nil {
+		t.Fatalf("expected an error for a blank keyword")
+	}
+
+	rr := httptest.NewRecorder()
+	writeError(rr, req, badRequest(err))
+	if rr.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected status code %d, got %d", http.StatusUnprocessableEntity, rr.Code)
+	}
+	if want := `"details":[{"field":"keyword","message":"is required"}]`; !strings.Contains(rr.Body.String(), want) {
+		t.Errorf("expected body to contain %s, got: %s", want, rr.Body.String())
+	}
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Code != codeValidation {
+		t.Errorf("expected a validation error, got %v", err)
+	}
+}
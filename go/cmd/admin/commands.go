@@ -0,0 +1,218 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"mercari-build-training/app"
+	"strconv"
+)
+
+func printItems(w io.Writer, items []app.Item) {
+	fmt.Fprintln(w, "ID\tNAME\tCATEGORY\tIMAGE")
+	for _, item := range items {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", item.ID, item.Name, item.Category, item.ImageName)
+	}
+}
+
+func (c *command) listItems(ctx context.Context, repo app.AdminRepository, args []string) error {
+	fs := flag.NewFlagSet("items list", flag.ContinueOnError)
+	category := fs.String("category", "", "only the items in this category")
+	keyword := fs.String("keyword", "", "only the items whose name contains this keyword")
+	if err := fs.Parse(args); err != nil {
+		return usageError(err.Error())
+	}
+	if fs.NArg() > 0 {
+		return usageError("items list: unexpected arguments")
+	}
+
+	items := []app.Item{}
+	err := repo.Stream(ctx, *keyword, *category, func(item app.Item) error {
+		items = append(items, item)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return c.print(items, func(w io.Writer) {
+		printItems(w, items)
+	})
+}
+
+func (c *command) getItem(ctx context.Context, repo app.AdminRepository, args []string) error {
+	if len(args) != 1 {
+		return usageError("items get: want ID")
+	}
+	item, err := repo.Get(ctx, args[0])
+	if err != nil {
+		return err
+	}
+	return c.print(item, func(w io.Writer) {
+		printItems(w, []app.Item{*item})
+	})
+}
+
+func (c *command) deleteItem(ctx context.Context, repo app.AdminRepository, args []string) error {
+	if len(args) != 1 {
+		return usageError("items delete: want ID")
+	}
+	id, err := strconv.Atoi(args[0])
+	if err != nil {
+		return usageError(fmt.Sprintf("items delete: invalid ID %q", args[0]))
+	}
+	if err := repo.Delete(ctx, id); err != nil {
+		return err
+	}
+	return c.printResult("deleted item %d", id)
+}
+
+func (c *command) listCategories(ctx context.Context, repo app.AdminRepository, args []string) error {
+	if len(args) != 0 {
+		return usageError("categories list: unexpected arguments")
+	}
+	categories, err := repo.ListCategories(ctx)
+	if err != nil {
+		return err
+	}
+	if categories == nil {
+		categories = []app.Category{}
+	}
+	return c.print(categories, func(w io.Writer) {
+		fmt.Fprintln(w, "ID\tNAME\tITEMS")
+		for _, category := range categories {
+			fmt.Fprintf(w, "%d\t%s\t%d\n", category.ID, category.Name, category.Items)
+		}
+	})
+}
+
+func (c *command) renameCategory(ctx context.Context, repo app.AdminRepository, args []string) error {
+	if len(args) != 2 {
+		return usageError("categories rename: want NAME NEW_NAME")
+	}
+	if err := repo.RenameCategory(ctx, args[0], args[1]); err != nil {
+		return err
+	}
+	return c.printResult("renamed category %q to %q", args[0], args[1])
+}
+
+func (c *command) deleteCategory(ctx context.Context, repo app.AdminRepository, args []string) error {
+	if len(args) != 1 {
+		return usageError("categories delete: want NAME")
+	}
+	if err := repo.DeleteCategory(ctx, args[0]); err != nil {
+		return err
+	}
+	return c.printResult("deleted category %q", args[0])
+}
+
+// migrate creates the tables of a new database and applies the pending migrations to an existing one.
+func (c *command) migrate(ctx context.Context, repo app.AdminRepository, args []string) error {
+	if len(args) != 0 {
+		return usageError("migrate: unexpected arguments")
+	}
+	before, err := repo.SchemaVersion(ctx)
+	if err != nil {
+		return err
+	}
+	if err := repo.Migrate(ctx, c.cfg.SchemaPath); err != nil {
+		return err
+	}
+	after, err := repo.SchemaVersion(ctx)
+	if err != nil {
+		return err
+	}
+	if before == after {
+		return c.printResult("schema is up to date at version %d", after)
+	}
+	return c.printResult("migrated schema from version %d to %d", before, after)
+}
+
+func (c *command) vacuum(ctx context.Context, repo app.AdminRepository, args []string) error {
+	if len(args) != 0 {
+		return usageError("vacuum: unexpected arguments")
+	}
+	before, err := repo.CatalogStats(ctx)
+	if err != nil {
+		return err
+	}
+	if err := repo.Vacuum(ctx); err != nil {
+		return err
+	}
+	after, err := repo.CatalogStats(ctx)
+	if err != nil {
+		return err
+	}
+	return c.printResult("vacuumed database from %d to %d bytes", before.DatabaseBytes, after.DatabaseBytes)
+}
+
+func (c *command) backup(ctx context.Context, repo app.AdminRepository, args []string) error {
+	if len(args) != 1 {
+		return usageError("backup: want FILE")
+	}
+	if err := repo.Backup(ctx, args[0]); err != nil {
+		return err
+	}
+	return c.printResult("backed up database to %s", args[0])
+}
+
+func (c *command) restore(ctx context.Context, path string) error {
+	if err := app.RestoreDatabase(ctx, c.cfg.DatabaseDSN, path); err != nil {
+		return err
+	}
+	// a backup of an older schema is restored as it is, and migrated by `admin migrate` or the server
+	repo, err := c.openRepo()
+	if err != nil {
+		return err
+	}
+	defer repo.Close()
+	version, err := repo.SchemaVersion(ctx)
+	if err != nil {
+		return err
+	}
+	return c.printResult("restored database at schema version %d of %d from %s", version, app.LatestSchemaVersion, path)
+}
+
+func (c *command) verifyImages(ctx context.Context, repo app.AdminRepository, args []string) error {
+	if len(args) != 0 {
+		return usageError("verify-images: unexpected arguments")
+	}
+	problems, err := app.VerifyImages(ctx, repo, c.cfg.ImageDir)
+	if err != nil {
+		return err
+	}
+	if err := c.print(problems, func(w io.Writer) {
+		if len(problems) == 0 {
+			fmt.Fprintln(w, "every image exists")
+			return
+		}
+		fmt.Fprintln(w, "ID\tNAME\tIMAGE\tPROBLEM")
+		for _, p := range problems {
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", p.ID, p.Name, p.ImageName, p.Problem)
+		}
+	}); err != nil {
+		return err
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%d items have no image in %s", len(problems), c.cfg.ImageDir)
+	}
+	return nil
+}
+
+func (c *command) stats(ctx context.Context, repo app.AdminRepository, args []string) error {
+	if len(args) != 0 {
+		return usageError("stats: unexpected arguments")
+	}
+	stats, err := repo.CatalogStats(ctx)
+	if err != nil {
+		return err
+	}
+	return c.print(stats, func(w io.Writer) {
+		fmt.Fprintf(w, "items\t%d\n", stats.Items)
+		fmt.Fprintf(w, "categories\t%d\n", stats.Categories)
+		fmt.Fprintf(w, "images\t%d\n", stats.Images)
+		fmt.Fprintf(w, "database bytes\t%d\n", stats.DatabaseBytes)
+		fmt.Fprintf(w, "free bytes\t%d\n", stats.FreeBytes)
+		fmt.Fprintf(w, "schema version\t%d of %d\n", stats.SchemaVersion, app.LatestSchemaVersion)
+	})
+}
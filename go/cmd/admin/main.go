@@ -0,0 +1,211 @@
+// Command admin runs the operational tasks of the catalog, such as deleting items or backing up the database,
+// through the same repository as the API server.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"mercari-build-training/app"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+)
+
+const usage = `usage: admin [flags] COMMAND [ARGS]
+
+commands:
+  items list [-category NAME] [-keyword KEYWORD]   list items
+  items get ID                                     show an item
+  items delete ID                                  delete an item
+  categories list                                  list categories with their number of items
+  categories rename NAME NEW_NAME                  rename a category
+  categories delete NAME                           delete a category without items
+  migrate                                          create the tables and apply the pending migrations
+  vacuum                                           reclaim the space of deleted rows
+  backup FILE                                      write a copy of the database to FILE
+  restore FILE                                     replace the database with a backup; stop the server first
+  verify-images                                    check that the image of every item exists
+  stats                                            show the statistics of the catalog
+
+flags:
+`
+
+// command is the environment of a subcommand.
+type command struct {
+	cfg      app.Config
+	jsonOut  bool
+	stdout   io.Writer
+	openRepo func() (app.AdminRepository, error)
+}
+
+func main() {
+	os.Exit(run(os.Args[1:]))
+}
+
+// run runs the admin command and returns the exit code: 1 if the command failed and 2 if it was misused.
+func run(args []string) int {
+	fs := flag.NewFlagSet("admin", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), usage)
+		fs.PrintDefaults()
+	}
+	configPath := fs.String("config", "", "path to a YAML config file of the server")
+	jsonOut := fs.Bool("json", false, "print JSON instead of tables; can also be given after the command")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+
+	// the database and the images directory are taken from the config file and the environment as the server does
+	var configArgs []string
+	if *configPath != "" {
+		configArgs = []string{"-config", *configPath}
+	}
+	cfg, err := app.LoadConfig(configArgs, os.LookupEnv)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	cfg.ImageDir, err = filepath.Abs(cfg.ImageDir)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	c := &command{
+		cfg:     cfg,
+		jsonOut: *jsonOut,
+		stdout:  os.Stdout,
+		openRepo: func() (app.AdminRepository, error) {
+			return app.OpenAdminRepository(cfg.DatabaseDSN)
+		},
+	}
+	err = c.run(context.Background(), fs.Args())
+	var usageErr usageError
+	if errors.As(err, &usageErr) {
+		fmt.Fprintln(os.Stderr, err)
+		fs.Usage()
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// usageError is returned when a command is called with wrong arguments.
+type usageError string
+
+func (e usageError) Error() string {
+	return string(e)
+}
+
+// run dispatches args to a subcommand.
+func (c *command) run(ctx context.Context, args []string) error {
+	// -json is accepted after the subcommand as well, e.g. `admin items list -json`
+	var rest []string
+	for _, arg := range args {
+		switch arg {
+		case "-json", "--json":
+			c.jsonOut = true
+		default:
+			rest = append(rest, arg)
+		}
+	}
+	if len(rest) == 0 {
+		return usageError("missing command")
+	}
+	name, args := rest[0], rest[1:]
+	switch name {
+	case "items", "categories":
+		if len(args) == 0 {
+			return usageError(name + ": missing subcommand")
+		}
+		name, args = name+" "+args[0], args[1:]
+	}
+
+	// restore replaces the database file, so it must not be open
+	if name == "restore" {
+		if len(args) != 1 {
+			return usageError("restore: want FILE")
+		}
+		return c.restore(ctx, args[0])
+	}
+
+	handler, ok := map[string]func(context.Context, app.AdminRepository, []string) error{
+		"items list":        c.listItems,
+		"items get":         c.getItem,
+		"items delete":      c.deleteItem,
+		"categories list":   c.listCategories,
+		"categories rename": c.renameCategory,
+		"categories delete": c.deleteCategory,
+		"migrate":           c.migrate,
+		"vacuum":            c.vacuum,
+		"backup":            c.backup,
+		"verify-images":     c.verifyImages,
+		"stats":             c.stats,
+	}[name]
+	if !ok {
+		return usageError(fmt.Sprintf("unknown command %q", name))
+	}
+
+	repo, err := c.openRepo()
+	if err != nil {
+		return err
+	}
+	defer repo.Close()
+
+	// only migrate changes the schema, and the other commands refuse an outdated one,
+	// except backup so that the database can be backed up before migrating.
+	switch name {
+	case "migrate", "backup":
+	default:
+		if err := checkSchema(ctx, repo); err != nil {
+			return err
+		}
+	}
+	return handler(ctx, repo, args)
+}
+
+// checkSchema fails if the schema of the database is behind the one of this command.
+func checkSchema(ctx context.Context, repo app.AdminRepository) error {
+	version, err := repo.SchemaVersion(ctx)
+	if err != nil {
+		return err
+	}
+	if version < app.LatestSchemaVersion {
+		return fmt.Errorf("the database schema is at version %d of %d; back it up and run `admin migrate` first", version, app.LatestSchemaVersion)
+	}
+	return nil
+}
+
+// print prints v as JSON if -json is given, and as a table written by table otherwise.
+func (c *command) print(v any, table func(w io.Writer)) error {
+	if c.jsonOut {
+		enc := json.NewEncoder(c.stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	}
+	tw := tabwriter.NewWriter(c.stdout, 0, 0, 2, ' ', 0)
+	table(tw)
+	return tw.Flush()
+}
+
+// printResult prints the result of a command which doesn't return data, e.g. {"result": "deleted item 1"}.
+func (c *command) printResult(format string, args ...any) error {
+	msg := fmt.Sprintf(format, args...)
+	return c.print(map[string]string{"result": msg}, func(w io.Writer) {
+		fmt.Fprintln(w, msg)
+	})
+}
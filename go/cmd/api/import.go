@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"mercari-build-training/app"
+	"os"
+	"path/filepath"
+)
+
+// runImport imports items from a CSV or JSON Lines file like POST /items/import, and prints the report.
+// The database and the images directory are taken from the config file and the environment as the server does.
+// It exits with 1 if any row isn't imported, so that scripts can tell a partial import.
+func runImport(args []string) int {
+	fs := flag.NewFlagSet("api import", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: api import [flags] FILE")
+		fmt.Fprintln(fs.Output(), "FILE is a CSV or JSON Lines file of items, or - for the standard input.")
+		fs.PrintDefaults()
+	}
+	configPath := fs.String("config", "", "path to a YAML config file")
+	format := fs.String("format", "", "csv or jsonl; taken from the file extension if empty")
+	dryRun := fs.Bool("dry-run", false, "validate the rows without importing them")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+	path := fs.Arg(0)
+	if *format == "" {
+		*format = app.ImportFormat(filepath.Ext(path))
+	}
+
+	cfg, err := loadCommandConfig(*configPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		defer f.Close()
+		r = f
+	}
+
+	repo, err := app.NewItemRepository(cfg.DatabaseDSN, cfg.SchemaPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer repo.Close()
+
+	im := &app.ItemImporter{Repo: repo, ImageDir: cfg.ImageDir, DryRun: *dryRun}
+	resp, err := im.Import(context.Background(), r, *format)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if err := printReport(resp); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if resp.Invalid > 0 || resp.Failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// runImportLegacy imports the items.json files of the JSON file repository used before SQLite, and prints the reports.
+// The items imported by an earlier run are skipped, so it can be rerun after fixing a failure.
+func runImportLegacy(args []string) int {
+	fs := flag.NewFlagSet("api import-legacy", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: api import-legacy [flags] FILE...")
+		fmt.Fprintln(fs.Output(), "FILE is an items.json of the JSON file repository.")
+		fs.PrintDefaults()
+	}
+	configPath := fs.String("config", "", "path to a YAML config file")
+	legacyImageDir := fs.String("legacy-image-dir", "", "directory of the images referred to by the files; the image directory if empty")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+
+	cfg, err := loadCommandConfig(*configPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	if *legacyImageDir == "" {
+		*legacyImageDir = cfg.ImageDir
+	}
+	*legacyImageDir, err = filepath.Abs(*legacyImageDir)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	repo, err := app.NewLegacyRepository(cfg.DatabaseDSN, cfg.SchemaPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer repo.Close()
+
+	im := &app.LegacyImporter{Repo: repo, ImageDir: cfg.ImageDir, LegacyImageDir: *legacyImageDir}
+	code := 0
+	for _, path := range fs.Args() {
+		resp, err := importLegacyFile(im, path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+			code = 1
+			continue
+		}
+		if err := printReport(resp); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		if resp.Invalid > 0 || resp.Failed > 0 {
+			code = 1
+		}
+	}
+	return code
+}
+
+func importLegacyFile(im *app.LegacyImporter, path string) (*app.LegacyImportResponse, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return im.Import(context.Background(), f)
+}
+
+// loadCommandConfig loads the configuration of a subcommand from the config file and the environment,
+// as the server does.
+func loadCommandConfig(configPath string) (app.Config, error) {
+	var args []string
+	if configPath != "" {
+		args = []string{"-config", configPath}
+	}
+	cfg, err := app.LoadConfig(args, os.LookupEnv)
+	if err != nil {
+		return app.Config{}, err
+	}
+	cfg.ImageDir, err = filepath.Abs(cfg.ImageDir)
+	if err != nil {
+		return app.Config{}, err
+	}
+	return cfg, nil
+}
+
+// printReport prints the report of an import as indented JSON.
+func printReport(report any) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(report)
+}
@@ -1,29 +1,42 @@
 package main
 
 import (
+	"errors"
+	"flag"
+	"fmt"
 	"mercari-build-training/app"
 	"os"
 	"path/filepath"
 )
 
-const (
-	port = "9000"
-	//imageDirPath = "images"
-)
-
 func main() {
+	// `api import FILE` and `api import-legacy FILE...` import items instead of starting the server
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "import":
+			os.Exit(runImport(os.Args[2:]))
+		case "import-legacy":
+			os.Exit(runImportLegacy(os.Args[2:]))
+		}
+	}
+
+	// Load the configuration from the flags, the environment and an optional config file
+	cfg, err := app.LoadConfig(os.Args[1:], os.LookupEnv)
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
 	// Get the absolute path to the images directory
-	currentDir, err := os.Getwd()
+	cfg.ImageDir, err = filepath.Abs(cfg.ImageDir)
 	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
-	imgDirPath := filepath.Join(currentDir, "images")
-	//追加ここまで
 
 	// This is the entry point of the application.
-	// You don't need to modify this function.
-	os.Exit(app.Server{
-		Port:         port,
-		ImageDirPath: imgDirPath,
-	}.Run())
+	os.Exit(app.Server{Config: cfg}.Run())
 }
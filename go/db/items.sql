@@ -11,5 +11,12 @@ CREATE TABLE IF NOT EXISTS items (
     FOREIGN KEY (category_id) REFERENCES categories(id) ON DELETE CASCADE
 );
 
-INSERT INTO categories (name) VALUES ('phone');
-INSERT INTO categories (name) VALUES ('fashion');
\ No newline at end of file
+CREATE TABLE IF NOT EXISTS images (
+    name TEXT PRIMARY KEY,
+    phash INTEGER NOT NULL
+);
+
+-- this file runs on every start, so the initial categories are only inserted into an empty table
+INSERT INTO categories (name)
+SELECT column1 FROM (VALUES ('phone'), ('fashion'))
+WHERE NOT EXISTS (SELECT 1 FROM categories);
\ No newline at end of file
@@ -5,12 +5,15 @@ go 1.24
 tool go.uber.org/mock/mockgen
 
 require (
+	github.com/andybalholm/brotli v1.2.0
 	github.com/google/go-cmp v0.7.0
+	github.com/mattn/go-sqlite3 v1.14.24
 	go.uber.org/mock v0.5.0
+	golang.org/x/text v0.16.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
-	github.com/mattn/go-sqlite3 v1.14.24 // indirect
 	golang.org/x/mod v0.18.0 // indirect
 	golang.org/x/sync v0.7.0 // indirect
 	golang.org/x/tools v0.22.0 // indirect
@@ -1,18 +1,45 @@
 const SERVER_URL = import.meta.env.VITE_BACKEND_URL || 'http://127.0.0.1:9000';
+// API_URL is the base URL of the version of the API this client is written for.
+export const API_URL = `${SERVER_URL}/v1`;
 
+// The types below mirror the schemas in go/openapi.json, which is generated from the server.
 export interface Item {
   id: number;
   name: string;
   category: string;
   image_name: string;
+  image_url: string;
+  links: {
+    self: string;
+    category: string;
+  };
 }
 
 export interface ItemListResponse {
   items: Item[];
 }
 
+export interface ErrorResponse {
+  error: {
+    code: string;
+    message: string;
+    details?: { field: string; message: string }[];
+    request_id?: string;
+  };
+}
+
+// errorMessage returns the message of an error response, or fallback if the body isn't one.
+const errorMessage = async (response: Response, fallback: string) => {
+  try {
+    const body: ErrorResponse = await response.json();
+    return body.error?.message || fallback;
+  } catch {
+    return fallback;
+  }
+};
+
 export const fetchItems = async (): Promise<ItemListResponse> => {
-  const response = await fetch(`${SERVER_URL}/items`, {
+  const response = await fetch(`${API_URL}/items`, {
     method: 'GET',
     mode: 'cors',
     headers: {
@@ -22,7 +49,9 @@ export const fetchItems = async (): Promise<ItemListResponse> => {
   });
 
   if (response.status >= 400) {
-    throw new Error('Failed to fetch items from the server');
+    throw new Error(
+      await errorMessage(response, 'Failed to fetch items from the server'),
+    );
   }
   return response.json();
 };
@@ -38,14 +67,16 @@ export const postItem = async (input: CreateItemInput): Promise<Response> => {
   data.append('name', input.name);
   data.append('category', input.category);
   data.append('image', input.image);
-  const response = await fetch(`${SERVER_URL}/items`, {
+  const response = await fetch(`${API_URL}/items`, {
     method: 'POST',
     mode: 'cors',
     body: data,
   });
 
   if (response.status >= 400) {
-    throw new Error('Failed to post item to the server');
+    throw new Error(
+      await errorMessage(response, 'Failed to post item to the server'),
+    );
   }
 
   return response;
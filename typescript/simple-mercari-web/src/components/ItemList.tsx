@@ -36,7 +36,7 @@ export const ItemList = ({ reload, onLoadCompleted }: Prop) => {
           <div key={item.id} className="ItemList">
             {/* TODO: Task 2: Show item images */}
             <img
-              src={`http://localhost:9000/images/${item.image_name}`} 
+              src={item.image_url} 
               alt={item.name}
               onError={(e) => { e.currentTarget.src = PLACEHOLDER_IMAGE; }} 
             />
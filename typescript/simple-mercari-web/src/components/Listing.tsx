@@ -58,7 +58,7 @@ export const Listing = ({ onListingCompleted }: Prop) => {
       })
       .catch((error) => {
         console.error('POST error:', error);
-        alert('Failed to list this item');
+        alert(`Failed to list this item: ${error.message}`);
       })
       .finally(() => {
         onListingCompleted();